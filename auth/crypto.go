@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encryptedPrefix marks a stored token as an envelope, laid out as
+// enc:v1:<key id>:<wrapped data key>:<ciphertext>, each part carrying its nonce
+const encryptedPrefix = "enc:v1:"
+
+// ErrUnknownKey is returned when a token was sealed with a key that is not in the keyring
+var ErrUnknownKey = errors.New("token encrypted with unknown key")
+
+// Keyring holds the master keys used to wrap per-token data keys.
+// New tokens are sealed with the primary key, older keys are kept to open tokens sealed before a rotation.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring returns a keyring whose primary key is id
+func NewKeyring(id string, key []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	if err := k.Add(id, key); err != nil {
+		return nil, err
+	}
+	k.primary = id
+	return k, nil
+}
+
+// Add registers an older key that is only used to open tokens
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return fmt.Errorf("invalid key id %q", id)
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("key %s must be 32 bytes, got %d", id, len(key))
+	}
+	k.keys[id] = key
+	return nil
+}
+
+// LoadKeyringFromEnv reads keys from an environment variable formatted as "id:base64key,id:base64key".
+// The first key is the primary key.
+func LoadKeyringFromEnv(name string) (*Keyring, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, fmt.Errorf("environment variable %s is empty", name)
+	}
+	return parseKeyring(strings.Split(value, ","))
+}
+
+// LoadKeyringFromFile reads keys from a file with one "id:base64key" per line.
+// The first key is the primary key; blank lines and lines starting with # are ignored.
+func LoadKeyringFromFile(path string) (*Keyring, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return parseKeyring(entries)
+}
+
+func parseKeyring(entries []string) (*Keyring, error) {
+	var k *Keyring
+	for _, entry := range entries {
+		id, encoded, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found {
+			return nil, fmt.Errorf("invalid key entry, expected id:base64key")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %s: %w", id, err)
+		}
+
+		if k == nil {
+			k, err = NewKeyring(id, key)
+		} else {
+			err = k.Add(id, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if k == nil {
+		return nil, fmt.Errorf("no keys found")
+	}
+	return k, nil
+}
+
+// Seal encrypts value with a fresh data key wrapped by the primary key.
+// A nil keyring stores the value as plaintext.
+func (k *Keyring) Seal(value string) (string, error) {
+	if k == nil {
+		return value, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err := seal(k.keys[k.primary], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(dataKey, []byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + k.primary + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Open decrypts a value produced by Seal. Plaintext values are returned unchanged,
+// so tokens stored before encryption was enabled keep working.
+func (k *Keyring) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", fmt.Errorf("token is encrypted but no keyring is configured")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed encrypted token")
+	}
+
+	key, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed data key: %w", err)
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed ciphertext: %w", err)
+	}
+
+	dataKey, err := open(key, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is plaintext or sealed with a key other than the primary key
+func (k *Keyring) NeedsRotation(value string) bool {
+	if k == nil {
+		return false
+	}
+	return !strings.HasPrefix(value, encryptedPrefix+k.primary+":")
+}
+
+// IsSealed reports whether value was produced by Seal
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
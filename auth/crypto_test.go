@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+// errAny matches any non-nil error in the table tests
+var errAny = errors.New("any error")
+
+// testKeyring builds a keyring whose primary key is ids[0], deriving each key from its id
+func testKeyring(t *testing.T, ids ...string) *Keyring {
+	t.Helper()
+	key := func(id string) []byte {
+		sum := sha256.Sum256([]byte(id))
+		return sum[:]
+	}
+
+	k, err := NewKeyring(ids[0], key(ids[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids[1:] {
+		if err := k.Add(id, key(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return k
+}
+
+func TestKeyringSealOpen(t *testing.T) {
+	k := testKeyring(t, "k1")
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"token", "eyJhbGciOiJIUzI1NiJ9.eyJleHAiOjF9.sig"},
+		{"colons", "a:b:c"},
+		{"unicode", "口袋48"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := k.Seal(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsSealed(sealed) {
+				t.Fatalf("Seal(%q) = %q, want an envelope", tt.value, sealed)
+			}
+
+			opened, err := k.Open(sealed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opened != tt.value {
+				t.Errorf("Open(Seal(%q)) = %q", tt.value, opened)
+			}
+		})
+	}
+}
+
+func TestKeyringOpen(t *testing.T) {
+	old := testKeyring(t, "k1")
+	sealed, err := old.Seal("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := testKeyring(t, "k2", "k1")
+	other := testKeyring(t, "k3")
+	var none *Keyring
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		value   string
+		want    string
+		wantErr error
+	}{
+		{"plaintext", old, "token", "token", nil},
+		{"plaintext without keyring", none, "token", "token", nil},
+		{"older key", rotated, sealed, "token", nil},
+		{"unknown key", other, sealed, "", ErrUnknownKey},
+		{"sealed without keyring", none, sealed, "", errAny},
+		{"malformed", old, encryptedPrefix + "k1:abc", "", errAny},
+		{"tampered", old, sealed[:len(sealed)-2] + "AA", "", errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.Open(tt.value)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Open() error = %v", err)
+			case tt.wantErr == errAny && err == nil, tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("Open() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Open() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringNeedsRotation(t *testing.T) {
+	old := testKeyring(t, "k1")
+	sealedOld, err := old.Seal("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := testKeyring(t, "k2", "k1")
+	sealedNew, err := rotated.Seal("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var none *Keyring
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		value   string
+		want    bool
+	}{
+		{"plaintext", rotated, "token", true},
+		{"older key", rotated, sealedOld, true},
+		{"primary key", rotated, sealedNew, false},
+		{"key id prefix", testKeyring(t, "k"), sealedOld, true},
+		{"no keyring", none, "token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.keyring.NeedsRotation(tt.value); got != tt.want {
+				t.Errorf("NeedsRotation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// DeviceProfile describes the device and app build pocket requests claim to come from
+type DeviceProfile struct {
+	AppVersion string
+	AppBuild   string
+	DeviceID   string
+	DeviceName string
+	Vendor     string
+	OSType     string
+	OSVersion  string
+	BuildTag   string
+	Host       string
+}
+
+// appInfo is the json layout of the appInfo header, in the field order the app sends
+type appInfo struct {
+	IMEI               string `json:"IMEI"`
+	AppBuild           string `json:"appBuild"`
+	AppVersion         string `json:"appVersion"`
+	DeviceID           string `json:"deviceId"`
+	DeviceName         string `json:"deviceName"`
+	OSType             string `json:"osType"`
+	OSVersion          string `json:"osVersion"`
+	PhoneName          string `json:"phoneName"`
+	PhoneSystemVersion string `json:"phoneSystemVersion"`
+	Vendor             string `json:"vendor"`
+}
+
+// DefaultDeviceProfile returns the MuMu emulator profile on app version 7.0.2
+func DefaultDeviceProfile() DeviceProfile {
+	return DeviceProfile{
+		AppVersion: "7.0.2",
+		AppBuild:   "22090903",
+		DeviceID:   "997bfc558cc69ae6",
+		DeviceName: "MuMu",
+		Vendor:     "Netease",
+		OSType:     "android",
+		OSVersion:  "6.0.1",
+		BuildTag:   "V417IR release-keys",
+		Host:       "pocketapi.48.cn",
+	}
+}
+
+// NewDeviceProfile returns the default profile with a device id derived from seed,
+// so the same account always presents the same device
+func NewDeviceProfile(seed string) DeviceProfile {
+	p := DefaultDeviceProfile()
+	sum := sha256.Sum256([]byte(seed))
+	p.DeviceID = hex.EncodeToString(sum[:8])
+	return p
+}
+
+// WithAppVersion returns a copy of the profile on another app version and build
+func (p DeviceProfile) WithAppVersion(version, build string) DeviceProfile {
+	p.AppVersion = version
+	p.AppBuild = build
+	return p
+}
+
+// UserAgent returns the User-Agent header of the profile
+func (p DeviceProfile) UserAgent() string {
+	return fmt.Sprintf("PocketFans201807/%s_%s (%s:Android %s;%s %s)", p.AppVersion, p.AppBuild, p.DeviceName, p.OSVersion, p.Vendor, p.BuildTag)
+}
+
+// AppInfo returns the appInfo header of the profile
+func (p DeviceProfile) AppInfo() string {
+	info, _ := json.Marshal(appInfo{
+		IMEI:               p.DeviceID,
+		AppBuild:           p.AppBuild,
+		AppVersion:         p.AppVersion,
+		DeviceID:           p.DeviceID,
+		DeviceName:         p.DeviceName,
+		OSType:             p.OSType,
+		OSVersion:          p.OSVersion,
+		PhoneName:          p.DeviceName,
+		PhoneSystemVersion: p.OSVersion,
+		Vendor:             p.Vendor,
+	})
+	return string(info)
+}
+
+// Header returns the full request header for the profile
+func (p DeviceProfile) Header() map[string]string {
+	return map[string]string{
+		"User-Agent":      p.UserAgent(),
+		"Host":            p.Host,
+		"Connection":      "Keep-Alive",
+		"Accept-Encoding": "gzip",
+		"appInfo":         p.AppInfo(),
+		"Content-Type":    "application/json; charset=UTF-8",
+	}
+}
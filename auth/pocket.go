@@ -1,16 +1,23 @@
 package auth
 
 import (
-	"context"
+	"fmt"
 	"github.com/kosmosCosmos/arc-golang-toolkit/tools"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/tidwall/gjson"
 )
 
+// TokenKey is the redis key the pocket token is stored under
+const TokenKey = "pocket_token"
+
+// TokenInfoKey is the redis hash holding the account details of the stored token
+const TokenInfoKey = "pocket_token_info"
+
 type Config struct {
 	SendSmsAPI string
 	LoginAPI   string
+	CheckAPI   string
 }
 
 type Authenticator struct {
@@ -19,11 +26,12 @@ type Authenticator struct {
 	header      map[string]string
 	config      Config
 	redisClient *redis.Client
+	keyring     *Keyring
 }
 
 // DefaultHeader returns the default header configuration
 func DefaultHeader() map[string]string {
-	return map[string]string{"User-Agent": "PocketFans201807/7.0.2_22090903 (MuMu:Android 6.0.1;Netease V417IR release-keys)", "Host": "pocketapi.48.cn", "Connection": "Keep-Alive", "Accept-Encoding": "gzip", "appInfo": `{"IMEI":"997bfc558cc69ae6","appBuild":"22090903","appVersion":"7.0.2","deviceId":"997bfc558cc69ae6","deviceName":"MuMu","osType":"android","osVersion":"6.0.1","phoneName":"MuMu","phoneSystemVersion":"6.0.1","vendor":"Netease"}`, "Content-Type": "application/json; charset=UTF-8"}
+	return DefaultDeviceProfile().Header()
 }
 
 func NewAuthenticator(phoneNumber, phoneArea string, redisClient *redis.Client) *Authenticator {
@@ -34,11 +42,24 @@ func NewAuthenticator(phoneNumber, phoneArea string, redisClient *redis.Client)
 		config: Config{
 			SendSmsAPI: "https://pocketapi.48.cn/user/api/v1/sms/send2",
 			LoginAPI:   "https://pocketapi.48.cn/user/api/v1/login/app/mobile/code",
+			CheckAPI:   "https://pocketapi.48.cn/user/api/v1/user/info/reload",
 		},
 		redisClient: redisClient,
 	}
 }
 
+// WithDeviceProfile sets the device the authenticator presents itself as
+func (a *Authenticator) WithDeviceProfile(profile DeviceProfile) *Authenticator {
+	a.header = profile.Header()
+	return a
+}
+
+// WithKeyring encrypts the stored token with keys from keyring
+func (a *Authenticator) WithKeyring(keyring *Keyring) *Authenticator {
+	a.keyring = keyring
+	return a
+}
+
 func (a *Authenticator) SendSMS() error {
 	payload := map[string]string{
 		"mobile": a.phoneNumber,
@@ -48,16 +69,23 @@ func (a *Authenticator) SendSMS() error {
 	return err
 }
 
-func (a *Authenticator) Login(verificationCode string) error {
+func (a *Authenticator) Login(verificationCode string) (*Token, error) {
 	payload := map[string]string{
 		"mobile": a.phoneNumber,
 		"code":   verificationCode,
 	}
 	_, body, err := tools.NewRequest("POST", a.config.LoginAPI, a.header, payload)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	token := parseLoginResponse(body)
+	if token.Value == "" {
+		return nil, fmt.Errorf("login failed: %s", gjson.Get(body, "message").String())
 	}
 
-	token := gjson.Get(body, "content.token").String()
-	return a.redisClient.Set(context.Background(), "pocket_token", token, 0).Err()
+	if err := a.saveToken(token); err != nil {
+		return nil, err
+	}
+	return token, nil
 }
@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CodeProvider supplies the SMS verification code after SendSMS has been called
+type CodeProvider interface {
+	Code(ctx context.Context) (string, error)
+}
+
+// CodeResetter is implemented by providers that can hold codes between logins
+type CodeResetter interface {
+	Reset()
+}
+
+// Relogin sends a new SMS, waits for the provider to supply the code and logs in with it.
+// Codes a provider received before the SMS are discarded so a stale code is never used.
+func (a *Authenticator) Relogin(ctx context.Context, provider CodeProvider) (*Token, error) {
+	if r, ok := provider.(CodeResetter); ok {
+		r.Reset()
+	}
+
+	if err := a.SendSMS(); err != nil {
+		return nil, fmt.Errorf("failed to send SMS: %w", err)
+	}
+
+	code, err := provider.Code(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verification code: %w", err)
+	}
+
+	return a.Login(code)
+}
+
+// StdinCodeProvider prompts for the code and reads it from a line of input.
+// A single reader goroutine owns the input so a cancelled Code never leaves a read behind.
+type StdinCodeProvider struct {
+	in     *bufio.Reader
+	prompt io.Writer
+	once   sync.Once
+	lines  chan stdinLine
+}
+
+type stdinLine struct {
+	line string
+	err  error
+}
+
+// NewStdinCodeProvider returns a provider reading codes from os.Stdin
+func NewStdinCodeProvider() *StdinCodeProvider {
+	return &StdinCodeProvider{
+		in:     bufio.NewReader(os.Stdin),
+		prompt: os.Stdout,
+		lines:  make(chan stdinLine),
+	}
+}
+
+func (s *StdinCodeProvider) read() {
+	for {
+		line, err := s.in.ReadString('\n')
+		s.lines <- stdinLine{line, err}
+		if err != nil {
+			close(s.lines)
+			return
+		}
+	}
+}
+
+// Reset discards lines typed before the new code was requested
+func (s *StdinCodeProvider) Reset() {
+	s.once.Do(func() { go s.read() })
+	for {
+		select {
+		case _, ok := <-s.lines:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *StdinCodeProvider) Code(ctx context.Context) (string, error) {
+	s.once.Do(func() { go s.read() })
+	fmt.Fprint(s.prompt, "verification code: ")
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r, ok := <-s.lines:
+		if !ok {
+			return "", fmt.Errorf("failed to read code from stdin: %w", io.EOF)
+		}
+		if r.err != nil && r.line == "" {
+			return "", fmt.Errorf("failed to read code from stdin: %w", r.err)
+		}
+		code := strings.TrimSpace(r.line)
+		if code == "" {
+			return "", fmt.Errorf("empty verification code")
+		}
+		return code, nil
+	}
+}
+
+// FileCodeProvider waits for a code to be written to a file and removes the file once the code is read
+type FileCodeProvider struct {
+	path     string
+	interval time.Duration
+}
+
+// NewFileCodeProvider returns a provider watching path for a code
+func NewFileCodeProvider(path string) *FileCodeProvider {
+	return &FileCodeProvider{
+		path:     path,
+		interval: time.Second,
+	}
+}
+
+// Reset removes a code file written before the new SMS was sent
+func (f *FileCodeProvider) Reset() {
+	if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove stale code file %s: %v", f.path, err)
+	}
+}
+
+func (f *FileCodeProvider) Code(ctx context.Context) (string, error) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		data, err := os.ReadFile(f.path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read code file: %w", err)
+		}
+
+		if code := strings.TrimSpace(string(data)); code != "" {
+			if err := os.Remove(f.path); err != nil {
+				return "", fmt.Errorf("failed to remove code file: %w", err)
+			}
+			return code, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WebhookCodeProvider receives codes over a local HTTP endpoint, e.g. from an SMS forwarding app.
+// The code is sent as POST /code with either a "code" form value or the code as the raw body.
+type WebhookCodeProvider struct {
+	server *http.Server
+	codes  chan string
+}
+
+// NewWebhookCodeProvider starts listening on addr and returns the provider
+func NewWebhookCodeProvider(addr string) (*WebhookCodeProvider, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	w := &WebhookCodeProvider{codes: make(chan string, 1)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/code", w.handleCode)
+	w.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go w.server.Serve(listener)
+	return w, nil
+}
+
+func (w *WebhookCodeProvider) handleCode(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	code := r.FormValue("code")
+	if code == "" {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+		if err != nil {
+			http.Error(rw, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		code = string(body)
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		http.Error(rw, "missing code", http.StatusBadRequest)
+		return
+	}
+
+	// Keep only the latest code so a stale one is never used for the next login
+	for {
+		select {
+		case w.codes <- code:
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		default:
+		}
+		select {
+		case <-w.codes:
+		default:
+		}
+	}
+}
+
+// Reset drops a code received before the new SMS was sent
+func (w *WebhookCodeProvider) Reset() {
+	select {
+	case <-w.codes:
+	default:
+	}
+}
+
+func (w *WebhookCodeProvider) Code(ctx context.Context) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case code := <-w.codes:
+		return code, nil
+	}
+}
+
+// Close stops the webhook server
+func (w *WebhookCodeProvider) Close() error {
+	return w.server.Close()
+}
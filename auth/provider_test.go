@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCodeProviderReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "code")
+	provider := &FileCodeProvider{path: path, interval: 10 * time.Millisecond}
+
+	if err := os.WriteFile(path, []byte("111111\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider.Reset()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("stale code file still exists: %v", err)
+	}
+
+	// Resetting without a file is fine
+	provider.Reset()
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		os.WriteFile(path, []byte(" 222222 \n"), 0o600)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	code, err := provider.Code(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "222222" {
+		t.Errorf("Code() = %q, want the code written after the reset", code)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("code file was not removed after reading: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kosmosCosmos/arc-golang-toolkit/tools"
+	"github.com/redis/go-redis/v9"
+	"github.com/tidwall/gjson"
+)
+
+// ErrNoToken is returned when no token has been stored yet
+var ErrNoToken = errors.New("no pocket token stored")
+
+// tokenGrace keeps an expired token in redis long enough for Check to report it as expired
+const tokenGrace = 7 * 24 * time.Hour
+
+// Token stores the pocket token together with the account it belongs to
+type Token struct {
+	Value     string
+	UserID    int64
+	Nickname  string
+	ExpiresAt time.Time
+	LoginAt   time.Time
+}
+
+// Expired reports whether the token is past its known expiry
+func (t *Token) Expired() bool {
+	return !t.ExpiresAt.IsZero() && !time.Now().Before(t.ExpiresAt)
+}
+
+// TokenStatus is the result of probing the stored token against pocket
+type TokenStatus struct {
+	Token     *Token
+	Valid     bool
+	Message   string
+	CheckedAt time.Time
+}
+
+// ExpiresIn returns the time left before the token expires, or zero when the expiry is unknown
+func (s *TokenStatus) ExpiresIn() time.Duration {
+	if s.Token == nil || s.Token.ExpiresAt.IsZero() {
+		return 0
+	}
+	return time.Until(s.Token.ExpiresAt)
+}
+
+// LoadToken reads the stored token and its account details
+func (a *Authenticator) LoadToken() (*Token, error) {
+	ctx := context.Background()
+	stored, err := a.redisClient.Get(ctx, TokenKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNoToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token: %w", err)
+	}
+
+	value, err := a.keyring.Open(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := a.redisClient.HGetAll(ctx, TokenInfoKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token info: %w", err)
+	}
+
+	userID, _ := strconv.ParseInt(info["user_id"], 10, 64)
+	return &Token{
+		Value:     value,
+		UserID:    userID,
+		Nickname:  info["nickname"],
+		ExpiresAt: parseUnix(info["expires_at"]),
+		LoginAt:   parseUnix(info["login_at"]),
+	}, nil
+}
+
+// Check calls a cheap authenticated endpoint and reports whether the stored token still works
+func (a *Authenticator) Check() (*TokenStatus, error) {
+	token, err := a.LoadToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Expired() {
+		return &TokenStatus{
+			Token:     token,
+			Message:   fmt.Sprintf("token expired at %s", token.ExpiresAt.Format(time.RFC3339)),
+			CheckedAt: time.Now(),
+		}, nil
+	}
+
+	header := make(map[string]string, len(a.header)+1)
+	for k, v := range a.header {
+		header[k] = v
+	}
+	header["token"] = token.Value
+
+	_, body, err := tools.NewRequest("POST", a.config.CheckAPI, header, map[string]string{"from": "appstart"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token: %w", err)
+	}
+
+	return &TokenStatus{
+		Token:     token,
+		Valid:     gjson.Get(body, "status").Int() == 200,
+		Message:   gjson.Get(body, "message").String(),
+		CheckedAt: time.Now(),
+	}, nil
+}
+
+// RotateKey re-encrypts the stored token with the primary key of the keyring.
+// It reports whether the token had to be rewritten.
+func (a *Authenticator) RotateKey() (bool, error) {
+	if a.keyring == nil {
+		return false, fmt.Errorf("no keyring configured")
+	}
+
+	ctx := context.Background()
+	stored, err := a.redisClient.Get(ctx, TokenKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, ErrNoToken
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load token: %w", err)
+	}
+
+	if !a.keyring.NeedsRotation(stored) {
+		return false, nil
+	}
+
+	value, err := a.keyring.Open(stored)
+	if err != nil {
+		return false, err
+	}
+
+	sealed, err := a.keyring.Seal(value)
+	if err != nil {
+		return false, err
+	}
+
+	if err := a.redisClient.SetArgs(ctx, TokenKey, sealed, redis.SetArgs{KeepTTL: true}).Err(); err != nil {
+		return false, fmt.Errorf("failed to store token: %w", err)
+	}
+	return true, nil
+}
+
+// saveToken stores the token and its account details. When the expiry is known both are kept
+// for a grace period past it, so Check reports the token as expired rather than missing.
+func (a *Authenticator) saveToken(token *Token) error {
+	var ttl time.Duration
+	if !token.ExpiresAt.IsZero() {
+		if token.Expired() {
+			return fmt.Errorf("token already expired at %s", token.ExpiresAt.Format(time.RFC3339))
+		}
+		ttl = time.Until(token.ExpiresAt) + tokenGrace
+	}
+
+	sealed, err := a.keyring.Seal(token.Value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+
+	ctx := context.Background()
+	_, err = a.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, TokenKey, sealed, ttl)
+		pipe.Del(ctx, TokenInfoKey)
+		pipe.HSet(ctx, TokenInfoKey, map[string]interface{}{
+			"user_id":    token.UserID,
+			"nickname":   token.Nickname,
+			"expires_at": formatUnix(token.ExpiresAt),
+			"login_at":   formatUnix(token.LoginAt),
+		})
+		if ttl > 0 {
+			pipe.Expire(ctx, TokenInfoKey, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+	return nil
+}
+
+// parseLoginResponse extracts the token and account details from a login response body
+func parseLoginResponse(body string) *Token {
+	content := gjson.Get(body, "content")
+	token := &Token{
+		Value:    content.Get("token").String(),
+		UserID:   content.Get("userInfo.userId").Int(),
+		Nickname: content.Get("userInfo.nickname").String(),
+		LoginAt:  time.Now(),
+	}
+	token.ExpiresAt = tokenExpiry(token.Value)
+	return token
+}
+
+// tokenExpiry reads the exp claim when the token is a JWT, and returns the zero time otherwise
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+
+	exp := gjson.GetBytes(claims, "exp").Int()
+	if exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(exp, 0)
+}
+
+func formatUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func parseUnix(s string) time.Time {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
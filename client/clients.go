@@ -1,6 +1,7 @@
 package client
 
 import (
+	"github.com/kosmosCosmos/arc-crawling-service/auth"
 	"github.com/kosmosCosmos/arc-crawling-service/client/doubanClient"
 	"github.com/kosmosCosmos/arc-crawling-service/client/pocketClient"
 	"github.com/redis/go-redis/v9"
@@ -21,11 +22,55 @@ func NewDoubanClient(ID string) *DoubanClient {
 	}
 }
 
+// NewDoubanGroupsClient returns a douban client crawling several groups with one shared crawler
+func NewDoubanGroupsClient(groups ...doubanClient.GroupConfig) *DoubanClient {
+	cfg := doubanClient.NewConfiguration()
+	for _, group := range groups {
+		cfg.WithGroup(group)
+	}
+	return NewDoubanClientWithConfig(cfg)
+}
+
+// NewDoubanClientWithConfig returns a douban client for a fully built configuration, e.g. one with a cookie session
+func NewDoubanClientWithConfig(cfg *doubanClient.Configuration) *DoubanClient {
+	return &DoubanClient{
+		ApiClient: doubanClient.NewAPIClient(cfg),
+	}
+}
+
+// WithAlertHandler registers a callback for the alerts raised when douban blocks the crawler
+func (d *DoubanClient) WithAlertHandler(handler func(doubanClient.Alert)) *DoubanClient {
+	d.ApiClient.AlertHandler = handler
+	return d
+}
+
+// WithStore keeps topics and replies in store instead of the mysql connection.
+// Passing a nil connection to the crawls then skips groups, users, polls, keyword tags and snapshots,
+// and UpdateGroupInfo returns doubanClient.ErrNoMysql.
+func (d *DoubanClient) WithStore(store doubanClient.Store) *DoubanClient {
+	d.ApiClient.Store = store
+	return d
+}
+
 func (d *DoubanClient) UpdateTopicAndReplies(conn *xorm.Engine) error {
 	d.ApiClient.MysqlClient = conn
 	return d.ApiClient.DoubanServiceApi.UpdateTopicAndReplies()
 }
 
+// SearchKeywords crawls the topics matching the keywords configured for each group
+func (d *DoubanClient) SearchKeywords(conn *xorm.Engine) error {
+	d.ApiClient.MysqlClient = conn
+	return d.ApiClient.DoubanServiceApi.SearchKeywords()
+}
+
+func (d *DoubanClient) UpdateGroupInfo(conn *xorm.Engine, groupId string) error {
+	d.ApiClient.MysqlClient = conn
+	if err := d.ApiClient.DoubanServiceApi.SyncTables(); err != nil {
+		return err
+	}
+	return d.ApiClient.DoubanServiceApi.UpdateGroupInfo(groupId)
+}
+
 func NewChannelClient() *ChannelPocketClient {
 	return &ChannelPocketClient{
 		ApiClient: pocketClient.NewAPIClient(pocketClient.NewConfiguration()),
@@ -37,3 +82,16 @@ func (c *ChannelPocketClient) UpdateChannel(mysqlConn *xorm.Engine, redisConn *r
 	c.ApiClient.RedisClient = redisConn
 	return c.ApiClient.PocketServiceApi.UpdateChannelInfo()
 }
+
+// WithAutoLogin logs in again through the authenticator whenever the pocket token expires
+func (c *ChannelPocketClient) WithAutoLogin(authenticator *auth.Authenticator, provider auth.CodeProvider) *ChannelPocketClient {
+	c.ApiClient.Authenticator = authenticator
+	c.ApiClient.CodeProvider = provider
+	return c
+}
+
+// WithTokenKeyring decrypts the stored pocket token with keys from keyring
+func (c *ChannelPocketClient) WithTokenKeyring(keyring *auth.Keyring) *ChannelPocketClient {
+	c.ApiClient.TokenKeyring = keyring
+	return c
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/araddon/dateparse"
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/kosmosCosmos/arc-golang-toolkit/tools"
 	"log"
 	"strconv"
 	"strings"
@@ -14,102 +13,250 @@ import (
 
 type DoubanApiService service
 
-func (d *DoubanApiService) UpdateTopicAndReplies() error {
-	if err := d.client.MysqlClient.Sync2(Topic{}); err != nil {
-		return fmt.Errorf("failed to sync Topic table: %w", err)
+// SyncTables creates or updates the store and, when MysqlClient is set, the tables of the other douban models
+func (d *DoubanApiService) SyncTables() error {
+	if err := d.store().Sync(); err != nil {
+		return err
+	}
+	if !d.hasMysql() {
+		log.Printf("MysqlClient is not set, groups, users, polls, keyword tags and snapshots are not recorded")
+		return d.migrateTopicStates()
 	}
 
-	if err := d.client.MysqlClient.Sync2(Reply{}); err != nil {
-		return fmt.Errorf("failed to sync Reply table: %w", err)
+	if err := d.client.MysqlClient.Sync2(Group{}, GroupMemberCount{}); err != nil {
+		return fmt.Errorf("failed to sync Group tables: %w", err)
 	}
 
-	//for page := 1; ; page++ {
-	url := fmt.Sprintf(d.client.cfg.API.DiscussionURL, d.client.cfg.Client.ID, strconv.Itoa((1-1)*50))
-	topics, err := d.parseTopic(url, d.client.cfg.Client.ID)
-	if err != nil {
-		return fmt.Errorf("failed to parse topics on page %d: %w", 1, err)
+	if err := d.client.MysqlClient.Sync2(Poll{}, PollOption{}); err != nil {
+		return fmt.Errorf("failed to sync Poll tables: %w", err)
 	}
 
-	if len(topics) == 0 {
-		//break
+	if err := d.client.MysqlClient.Sync2(TopicKeyword{}); err != nil {
+		return fmt.Errorf("failed to sync TopicKeyword table: %w", err)
 	}
 
-	if err := d.insertTopics(topics); err != nil {
-		return fmt.Errorf("failed to insert topics from page %d: %w", 1, err)
+	if err := d.client.MysqlClient.Sync2(DoubanUser{}); err != nil {
+		return fmt.Errorf("failed to sync DoubanUser table: %w", err)
 	}
 
-	log.Printf("Successfully inserted %d topics from page %d", len(topics), 1)
+	if err := d.client.MysqlClient.Sync2(TopicSnapshot{}, ReplySnapshot{}); err != nil {
+		return fmt.Errorf("failed to sync snapshot tables: %w", err)
+	}
 
-	for _, topic := range topics {
-		if err := d.updateRepliesByTopic(topic.TopicId); err != nil {
-			log.Printf("Failed to update replies for topic %s: %v", topic.TopicId, err)
+	return d.migrateTopicStates()
+}
+
+func (d *DoubanApiService) UpdateTopicAndReplies() error {
+	if err := d.SyncTables(); err != nil {
+		return err
+	}
+
+	if err := d.seedSchedule(); err != nil {
+		log.Printf("Failed to load the group schedule, crawling every group: %v", err)
+	}
+
+	for _, group := range d.client.schedule.due(d.client.cfg.Groups, time.Now()) {
+		if err := d.updateGroup(group); err != nil {
+			// A ban blocks every group, so there is no point in trying the rest
+			if d.isBlocking(err) {
+				return err
+			}
+			log.Printf("Failed to update group %s: %v", group.ID, err)
 		}
 	}
 
-	time.Sleep(time.Minute * 2)
-	//}
+	if err := d.RecrawlTopics(); err != nil {
+		return err
+	}
+
+	// Profiles are only shown next to topics, so they get what is left of the run
+	return d.updateQueuedUsers()
+}
 
-	return nil
+// UpdateGroup crawls a single group now, regardless of its schedule
+func (d *DoubanApiService) UpdateGroup(id string) error {
+	for _, group := range d.client.cfg.Groups {
+		if group.ID == id {
+			return d.updateGroup(group)
+		}
+	}
+	return fmt.Errorf("group %s is not configured", id)
 }
 
-func (d *DoubanApiService) updateRepliesByTopic(topicId string) error {
-	for start := 0; ; start += 100 {
-		url := fmt.Sprintf(d.client.cfg.API.TopicURL, topicId, strconv.Itoa(start))
-		replies, err := d.parseReplies(url, topicId, start == 0)
+func (d *DoubanApiService) updateGroup(group GroupConfig) error {
+	if err := d.refreshGroupIfStale(group.ID); err != nil {
+		if d.isBlocking(err) {
+			return err
+		}
+		log.Printf("Failed to refresh info of group %s: %v", group.ID, err)
+	}
+
+	// Topics are crawled once every page is listed, so the ones that grew most go first
+	interval := d.groupInterval(group)
+	var tasks []crawlTask
+	var listErr error
+	for page := 1; page <= group.Pages; page++ {
+		topics, err := d.listTopics(group.ID, (page-1)*50, interval)
 		if err != nil {
-			return fmt.Errorf("failed to parse replies on start %d: %w", start, err)
+			if d.isBlocking(err) {
+				return err
+			}
+			listErr = fmt.Errorf("failed to parse topics on page %d: %w", page, err)
+			break
+		}
+
+		if len(topics) == 0 {
+			break
 		}
 
-		if len(replies) == 0 {
+		toCrawl, err := d.upsertTopics(topics, interval, topicFlagCols...)
+		if err != nil {
+			listErr = fmt.Errorf("failed to insert topics from page %d: %w", page, err)
 			break
 		}
+		tasks = append(tasks, toCrawl...)
+
+		log.Printf("Successfully stored %d topics from page %d of group %s, %d need crawling", len(topics), page, group.ID, len(toCrawl))
 
-		if err := d.insertReplies(replies); err != nil {
-			return fmt.Errorf("failed to insert replies from start %d: %w", start, err)
+		if err := d.insertTopicSnapshots(topics, time.Now()); err != nil {
+			log.Printf("Failed to insert topic snapshots from page %d of group %s: %v", page, group.ID, err)
 		}
 
-		log.Printf("Successfully inserted %d replies from start %d for topic %s", len(replies), start, topicId)
+		for _, topic := range topics {
+			d.client.users.add(topic.UserId)
+		}
 
-		time.Sleep(time.Minute * 2)
+		// Pinned topics head the first page whatever their age, so only the others tell
+		// whether the next page can still hold recent topics
+		if !hasUnpinned(topics) {
+			break
+		}
 	}
 
-	affected, err := d.updateTopicStatus(topicId)
-	if affected == 0 {
-		return fmt.Errorf("no topic updated, possibly topic_id not found: %s, error is %v", topicId, err)
+	if err := d.crawlTopics(tasks); err != nil {
+		return err
+	}
+	if listErr != nil {
+		return listErr
 	}
 
+	if err := d.markGroupCrawled(group.ID, time.Now()); err != nil {
+		log.Printf("Failed to record crawl of group %s: %v", group.ID, err)
+	}
 	return nil
 }
 
-func (d *DoubanApiService) insertTopics(topics []*Topic) error {
-	if len(topics) == 0 {
-		return nil
+func hasUnpinned(topics []*Topic) bool {
+	for _, topic := range topics {
+		if !topic.Sticky {
+			return true
+		}
 	}
-
-	_, err := d.client.MysqlClient.Insert(topics)
-	return err
+	return false
 }
 
-func (d *DoubanApiService) parseTopic(url, groupId string) ([]*Topic, error) {
+func (d *DoubanApiService) groupInterval(group GroupConfig) time.Duration {
+	if group.Interval > 0 {
+		return group.Interval
+	}
+	return d.client.cfg.Client.Interval
+}
 
-	_, body, err := tools.NewRequest("GET", url, d.client.cfg.Client.Header, nil)
+// updateRepliesByTopic crawls every reply page of a topic, inserts the replies not stored yet
+// and reconciles the stored replies against the ones still on douban.
+// finish runs in the transaction of the last page, after the reconciliation.
+// It reports whether the topic turned out to be deleted.
+func (d *DoubanApiService) updateRepliesByTopic(topicId string, interval time.Duration, finish func(tx Store) error) (bool, error) {
+	stored, err := d.storedReplies(topicId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to request Douban page: %w", err)
+		return false, fmt.Errorf("failed to load stored replies: %w", err)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	seen := make(map[string]struct{})
+	for start := 0; ; start += 100 {
+		page, err := d.listReplies(topicId, start)
+		if start == 0 && PageStatusOf(err) == PageNotFound {
+			return true, d.markTopicDeleted(topicId)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to parse replies on start %d: %w", start, err)
+		}
+
+		// Stop at the first page without replies we have not seen, which also guards against
+		// douban answering out-of-range pages with the last page again
+		newPage := false
+		for _, cid := range page.cids {
+			if _, ok := seen[cid]; !ok {
+				newPage = true
+				break
+			}
+		}
+
+		if !newPage {
+			// The first page is stored even without replies, it carries the topic body
+			err := d.storeTopicPage(topicId, page.detail, nil, func(tx Store) error {
+				if err := d.reconcileReplies(tx, topicId, stored, seen); err != nil {
+					return fmt.Errorf("failed to reconcile replies: %w", err)
+				}
+				return finish(tx)
+			})
+			if err != nil {
+				return false, fmt.Errorf("failed to store page from start %d: %w", start, err)
+			}
+			return false, nil
+		}
+
+		// Every reply on the page gets a snapshot of its likes, only the recent ones not stored yet are inserted
+		var fresh, current []*Reply
+		for _, reply := range page.replies {
+			if _, ok := seen[reply.DataCid]; ok {
+				continue
+			}
+			seen[reply.DataCid] = struct{}{}
+			current = append(current, reply)
+			if _, ok := stored[reply.DataCid]; !ok && d.isRecentTime(reply.Time, interval) {
+				fresh = append(fresh, reply)
+			}
+		}
+		for _, cid := range page.cids {
+			seen[cid] = struct{}{}
+		}
+
+		if page.detail != nil || len(fresh) > 0 {
+			if err := d.storeTopicPage(topicId, page.detail, fresh, nil); err != nil {
+				return false, fmt.Errorf("failed to store page from start %d: %w", start, err)
+			}
+		}
+
+		if err := d.insertReplySnapshots(current, time.Now()); err != nil {
+			log.Printf("Failed to insert reply snapshots from start %d for topic %s: %v", start, topicId, err)
+		}
+
+		for _, reply := range fresh {
+			d.client.users.add(reply.UserId)
+		}
+
+		log.Printf("Successfully inserted %d replies from start %d for topic %s", len(fresh), start, topicId)
+	}
+}
+
+func (d *DoubanApiService) parseTopic(doc *goquery.Document, url, groupId string, interval time.Duration) ([]*Topic, error) {
+	d.validateSelectors(PageKindTopicList, url, doc)
+
+	sel := d.client.cfg.Selectors.TopicList
+	if doc.Find(sel.Table).Length() == 0 {
+		return nil, fmt.Errorf("discussion table not found on %s", url)
 	}
 
 	var topics []*Topic
-	doc.Find("table.olt tr:not(.th)").Each(func(i int, s *goquery.Selection) {
+	doc.Find(sel.Row).Each(func(i int, s *goquery.Selection) {
 		topic, err := d.extractTopicInfo(s, groupId)
 		if err != nil {
 			log.Printf("Failed to extract topic info: %v", err)
 			return
 		}
-		if d.isRecentTime(topic.LastReplyTime, d.client.cfg.Client.Interval) {
+		// Pinned topics are kept whatever their age so their flags stay up to date
+		if topic.Sticky || d.isRecentTime(topic.LastReplyTime, interval) {
 			topics = append(topics, topic)
 		}
 	})
@@ -117,8 +264,9 @@ func (d *DoubanApiService) parseTopic(url, groupId string) ([]*Topic, error) {
 }
 
 func (d *DoubanApiService) extractTopicInfo(s *goquery.Selection, groupId string) (*Topic, error) {
-	topicLink := s.Find("td.title a")
-	userLink := s.Find("td:nth-child(2) a")
+	sel := d.client.cfg.Selectors.TopicList
+	topicLink := s.Find(sel.Title)
+	userLink := s.Find(sel.User)
 
 	topicURL, exists := topicLink.Attr("href")
 	if !exists {
@@ -130,14 +278,17 @@ func (d *DoubanApiService) extractTopicInfo(s *goquery.Selection, groupId string
 		return nil, fmt.Errorf("user URL not found")
 	}
 
-	replyCountStr := s.Find("td.r-count").Text()
+	replyCountStr := s.Find(sel.ReplyCount).Text()
 	replyCountStr = strings.TrimSpace(replyCountStr)
 	replyCount, err := strconv.Atoi(replyCountStr)
 	if err != nil {
 		replyCount = 0
 	}
 
-	lastReplyTime := strings.TrimSpace(s.Find("td.time").Text())
+	lastReplyTime := strings.TrimSpace(s.Find(sel.LastReplyTime).Text())
+	has := func(selector string) bool {
+		return selector != "" && s.Find(selector).Length() > 0
+	}
 
 	return &Topic{
 		TopicId:       d.extractID(topicURL, "group/topic"),
@@ -146,10 +297,14 @@ func (d *DoubanApiService) extractTopicInfo(s *goquery.Selection, groupId string
 		UserId:        d.extractID(userURL, "people"),
 		UserUrl:       userURL,
 		Title:         strings.TrimSpace(topicLink.Text()),
-		TopicStatus:   "unused",
+		TopicStatus:   string(TopicNew),
 		GroupId:       groupId,
 		ReplyCount:    replyCount,
 		LastReplyTime: lastReplyTime,
+		Sticky:        has(sel.Sticky),
+		Elite:         has(sel.Elite),
+		Locked:        has(sel.Locked),
+		HasImages:     has(sel.HasImages),
 	}, nil
 }
 
@@ -157,87 +312,56 @@ func (d *DoubanApiService) extractID(url, prefix string) string {
 	return strings.Trim(strings.TrimPrefix(url, fmt.Sprintf("https://www.douban.com/%s/", prefix)), "/")
 }
 
-func (d *DoubanApiService) parseReplies(url, topicId string, updateTopicDetail bool) ([]*Reply, error) {
-	_, body, err := tools.NewRequest("GET", url, d.client.cfg.Client.Header, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to request topic page: %w", err)
+// parseReplies returns the replies of a page, and the ids of all replies on it
+// whether or not they could be extracted, so reconciling never mistakes them for deleted
+func (d *DoubanApiService) parseReplies(doc *goquery.Document, url, topicId string, withDetail bool) (*topicPage, error) {
+	page := &topicPage{}
+	if withDetail {
+		detail, err := d.parseTopicDetails(doc, topicId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic details: %w", err)
+		}
+		page.detail = detail
 	}
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
-	}
+	d.validateSelectors(PageKindTopic, url, doc)
 
-	if updateTopicDetail {
-		if err := d.updateTopicDetails(doc, topicId); err != nil {
-			return nil, fmt.Errorf("failed to update topic details: %w", err)
+	doc.Find(d.client.cfg.Selectors.Reply.Item).Each(func(i int, s *goquery.Selection) {
+		if cid, ok := s.Attr("data-cid"); ok {
+			page.cids = append(page.cids, cid)
 		}
-	}
 
-	var replies []*Reply
-	doc.Find(".comment-item").Each(func(i int, s *goquery.Selection) {
 		reply, err := d.extractReplyInfo(s, topicId)
 		if err != nil {
 			log.Printf("Failed to extract reply info: %v", err)
 			return
 		}
-		if d.isRecentTime(reply.Time, d.client.cfg.Client.Interval) {
-			replies = append(replies, reply)
-		}
+		page.replies = append(page.replies, reply)
 	})
 
-	return replies, nil
+	return page, nil
 }
 
-func (d *DoubanApiService) updateTopicDetails(doc *goquery.Document, topicId string) error {
-	createDate := strings.TrimSpace(doc.Find(".create-time").Text())
-	createTime, err := dateparse.ParseIn(createDate, time.Local)
+func (d *DoubanApiService) parseTopicDetails(doc *goquery.Document, topicId string) (*topicDetail, error) {
+	sel := d.client.cfg.Selectors.Topic
+	createDate := strings.TrimSpace(doc.Find(sel.CreateTime).Text())
+	createTime, err := dateparse.ParseIn(createDate, shanghai)
 	if err != nil {
-		return fmt.Errorf("failed to parse create time: %w", err)
-	}
-
-	content := strings.TrimSpace(doc.Find("#link-report > div > div").Text())
-
-	affected, err := d.updateTopicContentAndTime(topicId, content, createTime.Unix())
-	if err != nil {
-		return fmt.Errorf("failed to update topic: %w", err)
-	}
-
-	if affected == 0 {
-		return fmt.Errorf("no topic updated, possibly topic_id not found: %s", topicId)
-	}
-
-	return nil
-}
-
-func (d *DoubanApiService) updateTopicContentAndTime(topicId string, newContent string, newCreateTime int64) (int64, error) {
-	updateTopic := &Topic{
-		Content:    newContent,
-		CreateTime: newCreateTime,
+		return nil, fmt.Errorf("failed to parse create time: %w", err)
 	}
 
-	return d.client.MysqlClient.Where("topic_id = ?", topicId).
-		Cols("content", "create_time").
-		Update(updateTopic)
-}
-
-func (d *DoubanApiService) updateTopicStatus(topicId string) (int64, error) {
-	updateTopic := &Topic{
-		TopicStatus: "done",
-	}
-
-	return d.client.MysqlClient.Where("topic_id = ?", topicId).
-		Cols("topic_status").
-		Update(updateTopic)
-}
-
-func (d *DoubanApiService) insertReplies(replies []*Reply) error {
-	if len(replies) == 0 {
-		return nil
-	}
-
-	_, err := d.client.MysqlClient.Insert(replies)
-	return err
+	author := doc.Find(sel.Author).First()
+	authorURL := author.AttrOr("href", "")
+	body := doc.Find(sel.Body)
+	return &topicDetail{
+		authorName: strings.TrimSpace(author.Text()),
+		authorId:   d.extractID(authorURL, "people"),
+		authorURL:  authorURL,
+		content:    strings.TrimSpace(body.Text()),
+		rich:       extractRichContent(body),
+		createTime: createTime,
+		polls:      d.extractPolls(doc, topicId),
+	}, nil
 }
 
 func (d *DoubanApiService) extractReplyInfo(s *goquery.Selection, topicId string) (*Reply, error) {
@@ -245,14 +369,19 @@ func (d *DoubanApiService) extractReplyInfo(s *goquery.Selection, topicId string
 	if !exists {
 		return nil, fmt.Errorf("data-cid attribute not found")
 	}
-	username := s.Find(".user-face img").AttrOr("alt", "Unknown")
-	userURL := s.Find(".user-face a").AttrOr("href", "")
+	sel := d.client.cfg.Selectors.Reply
+	username := s.Find(sel.UserAvatar).AttrOr("alt", "Unknown")
+	userURL := s.Find(sel.UserLink).AttrOr("href", "")
 	if userURL == "" {
 		return nil, fmt.Errorf("user URL not found")
 	}
 
-	replyContent := strings.TrimSpace(s.Find(".reply-content").Text())
-	timeIp := strings.TrimSpace(s.Find(".pubtime").Text())
+	quote := s.Find(sel.Quote).First()
+	content := s.Find(sel.Content).Clone()
+	content.Find(sel.Quote).Remove()
+	replyContent := strings.TrimSpace(content.Text())
+	replyImages := contentImages(content)
+	timeIp := strings.TrimSpace(s.Find(sel.PubTime).Text())
 	timeIp = strings.ReplaceAll(timeIp, "\n", " ")
 
 	timeParts := strings.Fields(timeIp)
@@ -265,7 +394,7 @@ func (d *DoubanApiService) extractReplyInfo(s *goquery.Selection, topicId string
 	} else {
 		replyTime = timeIp
 	}
-	likeCountStr := s.Find(".reply-opts .comment-vote .count").Text()
+	likeCountStr := s.Find(sel.LikeCount).Text()
 	likeCountStr = strings.TrimSpace(likeCountStr)
 	if likeCountStr == "" {
 		likeCountStr = "0"
@@ -275,39 +404,60 @@ func (d *DoubanApiService) extractReplyInfo(s *goquery.Selection, topicId string
 		likeCount = 0
 	}
 
-	return &Reply{
+	reply := &Reply{
 		TopicId:   topicId,
 		Username:  username,
+		UserId:    d.extractID(userURL, "people"),
 		UserURL:   userURL,
 		Content:   replyContent,
 		Time:      replyTime,
 		IP:        replyIP,
 		DataCid:   dataCid,
 		LikeCount: likeCount,
-	}, nil
+		Images:    replyImages,
+	}
+	if quote.Length() > 0 {
+		d.extractQuoteInfo(quote, reply)
+	}
+	return reply, nil
 }
 
-func (d *DoubanApiService) isRecentTime(dateStr string, interval time.Duration) bool {
-	parsedTime, _ := d.parseDateTime(dateStr)
-	isRecent := parsedTime.Before(time.Now().Add(interval))
-	log.Printf("Time: %s, is recent: %t", parsedTime.Format("2006-01-02 15:04"), isRecent)
-	return isRecent
+// extractQuoteInfo stores the reply being answered. Douban only sometimes renders the quoted
+// reply's id, so the author and text are kept as well to match it when the id is missing.
+func (d *DoubanApiService) extractQuoteInfo(quote *goquery.Selection, reply *Reply) {
+	reply.QuoteCid = quote.AttrOr("data-ref-cid", "")
+	if reply.QuoteCid == "" {
+		reply.QuoteCid = quote.Find("[data-ref-cid]").AttrOr("data-ref-cid", "")
+	}
+
+	sel := d.client.cfg.Selectors.Reply
+	author := quote.Find(sel.QuoteAuthor)
+	reply.QuoteUserName = strings.TrimSpace(author.Text())
+	if href, ok := author.Attr("href"); ok {
+		reply.QuoteUserId = d.extractID(href, "people")
+	}
+
+	text := quote.Find(sel.QuoteText)
+	if text.Length() == 0 {
+		text = quote.Find(sel.QuoteShort)
+	}
+	reply.QuoteContent = strings.TrimSpace(text.Text())
 }
 
-func (d *DoubanApiService) parseDateTime(dateStr string) (time.Time, error) {
-	fullFormat := "2006-01-02 15:04:05"
-	t, err := time.Parse(fullFormat, dateStr)
-	if err == nil {
-		return t, nil
+// isRecentTime reports whether dateStr lies within the last interval, a zero interval keeping every time.
+// Times that cannot be parsed are kept, so a new douban time format never silently drops data.
+func (d *DoubanApiService) isRecentTime(dateStr string, interval time.Duration) bool {
+	if interval <= 0 {
+		return true
 	}
 
-	shortFormat := "01-02 15:04"
-	t, err = time.Parse(shortFormat, dateStr)
-	if err == nil {
-		currentYear := time.Now().Year()
-		t = t.AddDate(currentYear, 0, 0)
-		return t, nil
+	parsedTime, err := parseDoubanTime(dateStr, time.Now())
+	if err != nil {
+		log.Printf("Failed to parse time, keeping it: %v", err)
+		return true
 	}
 
-	return time.Time{}, fmt.Errorf("invalid time format: %s", dateStr)
+	isRecent := parsedTime.After(time.Now().Add(-interval))
+	log.Printf("Time: %s, is recent: %t", parsedTime.Format("2006-01-02 15:04"), isRecent)
+	return isRecent
 }
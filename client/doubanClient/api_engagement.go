@@ -0,0 +1,200 @@
+package doubanClient
+
+import (
+	"sort"
+	"time"
+)
+
+// Engagement is the activity of a topic over a time window
+type Engagement struct {
+	TopicId        string  `json:"topic_id"`
+	Title          string  `json:"title"`
+	ReplyCount     int     `json:"reply_count"`
+	RepliesPerHour float64 `json:"replies_per_hour"`
+	LikesPerHour   float64 `json:"likes_per_hour"`
+}
+
+// Score ranks topics for HotTopics, counting a new reply and a new like the same
+func (e Engagement) Score() float64 {
+	return e.RepliesPerHour + e.LikesPerHour
+}
+
+func (d *DoubanApiService) insertTopicSnapshots(topics []*Topic, crawlTime time.Time) error {
+	if len(topics) == 0 || !d.hasMysql() {
+		return nil
+	}
+
+	snapshots := make([]*TopicSnapshot, 0, len(topics))
+	for _, topic := range topics {
+		snapshots = append(snapshots, &TopicSnapshot{
+			TopicId:    topic.TopicId,
+			GroupId:    topic.GroupId,
+			ReplyCount: topic.ReplyCount,
+			CrawlTime:  crawlTime.Unix(),
+		})
+	}
+
+	_, err := d.client.MysqlClient.Insert(snapshots)
+	return err
+}
+
+func (d *DoubanApiService) insertReplySnapshots(replies []*Reply, crawlTime time.Time) error {
+	if len(replies) == 0 || !d.hasMysql() {
+		return nil
+	}
+
+	snapshots := make([]*ReplySnapshot, 0, len(replies))
+	for _, reply := range replies {
+		snapshots = append(snapshots, &ReplySnapshot{
+			TopicId:   reply.TopicId,
+			DataCid:   reply.DataCid,
+			LikeCount: reply.LikeCount,
+			CrawlTime: crawlTime.Unix(),
+		})
+	}
+
+	return insertChunked(d.client.MysqlClient, snapshots, d.client.cfg.Client.MaxPacketSize, func(*ReplySnapshot) int { return rowOverhead })
+}
+
+// TopicEngagement computes the reply and like velocity of a topic over the last window
+func (d *DoubanApiService) TopicEngagement(topicId string, window time.Duration) (*Engagement, error) {
+	if !d.hasMysql() {
+		return nil, ErrNoMysql
+	}
+
+	since := time.Now().Add(-window).Unix()
+
+	var topicSnapshots []TopicSnapshot
+	err := d.client.MysqlClient.Where("topic_id = ? AND crawl_time >= ?", topicId, since).
+		Asc("crawl_time").
+		Find(&topicSnapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	var replySnapshots []ReplySnapshot
+	err = d.client.MysqlClient.Where("topic_id = ? AND crawl_time >= ?", topicId, since).
+		Asc("crawl_time").
+		Find(&replySnapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Engagement{TopicId: topicId}
+	e.ReplyCount, e.RepliesPerHour = replyVelocity(topicSnapshots)
+	e.LikesPerHour = likeVelocity(replySnapshots)
+	return e, nil
+}
+
+// HotTopics ranks the topics of a group by engagement over the last window
+func (d *DoubanApiService) HotTopics(groupId string, window time.Duration, limit int) ([]Engagement, error) {
+	if !d.hasMysql() {
+		return nil, ErrNoMysql
+	}
+
+	since := time.Now().Add(-window).Unix()
+
+	var topicSnapshots []TopicSnapshot
+	err := d.client.MysqlClient.Where("group_id = ? AND crawl_time >= ?", groupId, since).
+		Asc("crawl_time").
+		Find(&topicSnapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	byTopic := make(map[string][]TopicSnapshot)
+	for _, snapshot := range topicSnapshots {
+		byTopic[snapshot.TopicId] = append(byTopic[snapshot.TopicId], snapshot)
+	}
+	if len(byTopic) == 0 {
+		return nil, nil
+	}
+
+	topicIds := make([]string, 0, len(byTopic))
+	for topicId := range byTopic {
+		topicIds = append(topicIds, topicId)
+	}
+
+	var replySnapshots []ReplySnapshot
+	err = d.client.MysqlClient.In("topic_id", topicIds).And("crawl_time >= ?", since).
+		Asc("crawl_time").
+		Find(&replySnapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	repliesByTopic := make(map[string][]ReplySnapshot)
+	for _, snapshot := range replySnapshots {
+		repliesByTopic[snapshot.TopicId] = append(repliesByTopic[snapshot.TopicId], snapshot)
+	}
+
+	var topics []Topic
+	if err := d.client.MysqlClient.In("topic_id", topicIds).Cols("topic_id", "title").Find(&topics); err != nil {
+		return nil, err
+	}
+	titles := make(map[string]string, len(topics))
+	for _, topic := range topics {
+		titles[topic.TopicId] = topic.Title
+	}
+
+	ranking := make([]Engagement, 0, len(byTopic))
+	for topicId, snapshots := range byTopic {
+		e := Engagement{TopicId: topicId, Title: titles[topicId]}
+		e.ReplyCount, e.RepliesPerHour = replyVelocity(snapshots)
+		e.LikesPerHour = likeVelocity(repliesByTopic[topicId])
+		ranking = append(ranking, e)
+	}
+
+	sort.Slice(ranking, func(i, j int) bool {
+		return ranking[i].Score() > ranking[j].Score()
+	})
+	if limit > 0 && len(ranking) > limit {
+		ranking = ranking[:limit]
+	}
+	return ranking, nil
+}
+
+// replyVelocity returns the latest reply count and the replies per hour between the first and last snapshot
+func replyVelocity(snapshots []TopicSnapshot) (int, float64) {
+	if len(snapshots) == 0 {
+		return 0, 0
+	}
+
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	hours := float64(last.CrawlTime-first.CrawlTime) / 3600
+	if hours <= 0 {
+		return last.ReplyCount, 0
+	}
+	return last.ReplyCount, float64(last.ReplyCount-first.ReplyCount) / hours
+}
+
+// likeVelocity returns the likes per hour gained by all replies, between the first and last snapshot of the topic
+func likeVelocity(snapshots []ReplySnapshot) float64 {
+	if len(snapshots) == 0 {
+		return 0
+	}
+
+	firstLikes := make(map[string]int)
+	lastLikes := make(map[string]int)
+	start, end := snapshots[0].CrawlTime, snapshots[0].CrawlTime
+	for _, snapshot := range snapshots {
+		if _, ok := firstLikes[snapshot.DataCid]; !ok {
+			firstLikes[snapshot.DataCid] = snapshot.LikeCount
+		}
+		lastLikes[snapshot.DataCid] = snapshot.LikeCount
+		if snapshot.CrawlTime > end {
+			end = snapshot.CrawlTime
+		}
+	}
+
+	hours := float64(end-start) / 3600
+	if hours <= 0 {
+		return 0
+	}
+
+	gained := 0
+	for cid, likes := range lastLikes {
+		gained += likes - firstLikes[cid]
+	}
+	return float64(gained) / hours
+}
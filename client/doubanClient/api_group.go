@@ -0,0 +1,189 @@
+package doubanClient
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	memberCountPattern = regexp.MustCompile(`\((\d+)\)`)
+	datePattern        = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+)
+
+// UpdateGroupInfo crawls the group home page, stores its metadata and records the member count
+func (d *DoubanApiService) UpdateGroupInfo(groupId string) error {
+	if !d.hasMysql() {
+		return ErrNoMysql
+	}
+
+	group, err := d.parseGroup(groupId)
+	if err != nil {
+		return err
+	}
+
+	existing := &Group{}
+	found, err := d.client.MysqlClient.Where("group_id = ?", groupId).Get(existing)
+	if err != nil {
+		return fmt.Errorf("failed to query group %s: %w", groupId, err)
+	}
+
+	if found {
+		_, err = d.client.MysqlClient.ID(existing.Id).AllCols().Omit("crawl_time").Update(group)
+	} else {
+		_, err = d.client.MysqlClient.Insert(group)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store group %s: %w", groupId, err)
+	}
+
+	snapshot := &GroupMemberCount{
+		GroupId:     groupId,
+		MemberCount: group.MemberCount,
+		CrawlTime:   group.UpdateTime,
+	}
+	if _, err := d.client.MysqlClient.Insert(snapshot); err != nil {
+		return fmt.Errorf("failed to insert member count for group %s: %w", groupId, err)
+	}
+
+	return nil
+}
+
+// GroupMemberHistory returns the member counts recorded for a group since the given time, oldest first
+func (d *DoubanApiService) GroupMemberHistory(groupId string, since time.Time) ([]GroupMemberCount, error) {
+	if !d.hasMysql() {
+		return nil, ErrNoMysql
+	}
+
+	var history []GroupMemberCount
+	err := d.client.MysqlClient.Where("group_id = ? AND crawl_time >= ?", groupId, since.Unix()).
+		Asc("crawl_time").
+		Find(&history)
+	return history, err
+}
+
+// refreshGroupIfStale updates the group metadata when it is older than the configured refresh period
+func (d *DoubanApiService) refreshGroupIfStale(groupId string) error {
+	if !d.hasMysql() {
+		return nil
+	}
+
+	existing := &Group{}
+	found, err := d.client.MysqlClient.Where("group_id = ?", groupId).Cols("update_time").Get(existing)
+	if err != nil {
+		return fmt.Errorf("failed to query group %s: %w", groupId, err)
+	}
+
+	if found && time.Since(time.Unix(existing.UpdateTime, 0)) < d.client.cfg.Client.GroupRefresh {
+		return nil
+	}
+	return d.UpdateGroupInfo(groupId)
+}
+
+// markGroupCrawled records when the topics of a group were crawled, in memory and in the group row
+func (d *DoubanApiService) markGroupCrawled(groupId string, at time.Time) error {
+	d.client.schedule.markCrawled(groupId, at)
+	if !d.hasMysql() {
+		return nil
+	}
+
+	affected, err := d.client.MysqlClient.Where("group_id = ?", groupId).Cols("crawl_time").Update(&Group{CrawlTime: at.Unix()})
+	if err != nil {
+		return fmt.Errorf("failed to store crawl time of group %s: %w", groupId, err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// The group info could not be crawled yet, keep the crawl time in a placeholder row it will fill
+	found, err := d.client.MysqlClient.Where("group_id = ?", groupId).Exist(&Group{})
+	if err != nil {
+		return fmt.Errorf("failed to query group %s: %w", groupId, err)
+	}
+	if found {
+		return nil
+	}
+	_, err = d.client.MysqlClient.Insert(&Group{GroupId: groupId, Admins: []string{}, Tags: []string{}, CrawlTime: at.Unix()})
+	if err != nil {
+		return fmt.Errorf("failed to store crawl time of group %s: %w", groupId, err)
+	}
+	return nil
+}
+
+// seedSchedule loads the crawl times of earlier runs into the schedule, once per client
+func (d *DoubanApiService) seedSchedule() error {
+	if d.client.schedule.isSeeded() {
+		return nil
+	}
+	if !d.hasMysql() {
+		d.client.schedule.seed(nil)
+		return nil
+	}
+
+	var groups []Group
+	if err := d.client.MysqlClient.Where("crawl_time > 0").Cols("group_id", "crawl_time").Find(&groups); err != nil {
+		return fmt.Errorf("failed to load group crawl times: %w", err)
+	}
+
+	stored := make(map[string]time.Time, len(groups))
+	for _, group := range groups {
+		stored[group.GroupId] = time.Unix(group.CrawlTime, 0)
+	}
+	d.client.schedule.seed(stored)
+	return nil
+}
+
+func (d *DoubanApiService) parseGroup(groupId string) (*Group, error) {
+	url := fmt.Sprintf(d.client.cfg.API.GroupURL, groupId)
+	doc, err := d.fetch(url)
+	if err != nil {
+		return nil, err
+	}
+
+	d.validateSelectors(PageKindGroup, url, doc)
+
+	sel := d.client.cfg.Selectors.Group
+	name := strings.TrimSpace(doc.Find(sel.Name).Text())
+	if name == "" {
+		return nil, fmt.Errorf("group name not found for %s", groupId)
+	}
+
+	group := &Group{
+		GroupId:     groupId,
+		Name:        name,
+		Description: strings.TrimSpace(doc.Find(sel.Intro).Text()),
+		UpdateTime:  time.Now().Unix(),
+		Admins:      []string{},
+		Tags:        []string{},
+	}
+
+	membersText := doc.Find(sel.Members).Text()
+	if m := memberCountPattern.FindStringSubmatch(membersText); m != nil {
+		group.MemberCount, _ = strconv.Atoi(m[1])
+	}
+
+	board := doc.Find(sel.Board)
+	if date := datePattern.FindString(board.Text()); date != "" {
+		if created, err := time.ParseInLocation("2006-01-02", date, shanghai); err == nil {
+			group.CreateTime = created.Unix()
+		}
+	}
+
+	board.Find(sel.Admins).Each(func(i int, s *goquery.Selection) {
+		if href, ok := s.Attr("href"); ok {
+			group.Admins = append(group.Admins, d.extractID(href, "people"))
+		}
+	})
+
+	doc.Find(sel.Tags).Each(func(i int, s *goquery.Selection) {
+		if tag := strings.TrimSpace(s.Text()); tag != "" {
+			group.Tags = append(group.Tags, tag)
+		}
+	})
+
+	return group, nil
+}
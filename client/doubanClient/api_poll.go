@@ -0,0 +1,133 @@
+package doubanClient
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/araddon/dateparse"
+)
+
+var (
+	voteCountPattern = regexp.MustCompile(`(\d+)`)
+	deadlinePattern  = regexp.MustCompile(`\d{4}-\d{1,2}-\d{1,2}(?:\s+\d{1,2}:\d{2}(?::\d{2})?)?`)
+)
+
+// extractPolls returns the polls embedded in the first page of a topic with their current counts
+func (d *DoubanApiService) extractPolls(doc *goquery.Document, topicId string) []*Poll {
+	sel := d.client.cfg.Selectors.Poll
+	var polls []*Poll
+	doc.Find(sel.Item).Each(func(i int, s *goquery.Selection) {
+		poll := &Poll{
+			TopicId: topicId,
+			PollId:  s.AttrOr("data-id", s.AttrOr("id", topicId+"-"+strconv.Itoa(i))),
+			Title:   strings.TrimSpace(s.Find(sel.Title).First().Text()),
+		}
+
+		if date := deadlinePattern.FindString(s.Find(sel.Deadline).Text()); date != "" {
+			if deadline, err := dateparse.ParseIn(date, shanghai); err == nil {
+				poll.Deadline = deadline.Unix()
+			}
+		}
+
+		s.Find(sel.Option).Each(func(position int, o *goquery.Selection) {
+			option := &PollOption{
+				TopicId:  topicId,
+				PollId:   poll.PollId,
+				Position: position,
+				Title:    strings.TrimSpace(o.Find(sel.Text).Text()),
+			}
+			if m := voteCountPattern.FindString(o.Find(sel.Count).Text()); m != "" {
+				option.VoteCount, _ = strconv.Atoi(m)
+			}
+			poll.VoteCount += option.VoteCount
+			poll.Options = append(poll.Options, option)
+		})
+
+		if len(poll.Options) > 0 {
+			polls = append(polls, poll)
+		}
+	})
+	return polls
+}
+
+// storePolls upserts the polls and snapshots the counts of their options
+func (d *DoubanApiService) storePolls(polls []*Poll, crawlTime time.Time) error {
+	if !d.hasMysql() {
+		return nil
+	}
+
+	db := d.client.MysqlClient
+	var options []*PollOption
+	for _, poll := range polls {
+		poll.CrawlTime = crawlTime.Unix()
+
+		existing := &Poll{}
+		found, err := db.Where("topic_id = ? AND poll_id = ?", poll.TopicId, poll.PollId).
+			Cols("id").
+			Get(existing)
+		if err != nil {
+			return err
+		}
+
+		if found {
+			_, err = db.ID(existing.Id).
+				Cols("title", "deadline", "vote_count", "crawl_time").
+				Update(poll)
+		} else {
+			_, err = db.Insert(poll)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to store poll %s: %w", poll.PollId, err)
+		}
+
+		for _, option := range poll.Options {
+			option.CrawlTime = poll.CrawlTime
+			options = append(options, option)
+		}
+	}
+
+	if len(options) == 0 {
+		return nil
+	}
+	_, err := db.Insert(options)
+	return err
+}
+
+// TopicPolls returns the polls of a topic with the option counts of their latest crawl
+func (d *DoubanApiService) TopicPolls(topicId string) ([]*Poll, error) {
+	if !d.hasMysql() {
+		return nil, ErrNoMysql
+	}
+
+	var polls []*Poll
+	if err := d.client.MysqlClient.Where("topic_id = ?", topicId).Find(&polls); err != nil {
+		return nil, err
+	}
+
+	for _, poll := range polls {
+		err := d.client.MysqlClient.Where("poll_id = ? AND crawl_time = ?", poll.PollId, poll.CrawlTime).
+			Asc("position").
+			Find(&poll.Options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return polls, nil
+}
+
+// PollHistory returns every snapshot of the options of a poll, oldest first
+func (d *DoubanApiService) PollHistory(pollId string) ([]*PollOption, error) {
+	if !d.hasMysql() {
+		return nil, ErrNoMysql
+	}
+
+	var options []*PollOption
+	err := d.client.MysqlClient.Where("poll_id = ?", pollId).
+		Asc("crawl_time", "position").
+		Find(&options)
+	return options, err
+}
@@ -0,0 +1,81 @@
+package doubanClient
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractPolls(t *testing.T) {
+	deadline := func(year int, month time.Month, day, hour, min int) int64 {
+		return time.Date(year, month, day, hour, min, 0, 0, shanghai).Unix()
+	}
+	option := func(text, count string) string {
+		return `<li class="poll-option"><span class="poll-option-text">` + text + `</span><span class="poll-option-count">` + count + `</span></li>`
+	}
+
+	tests := []struct {
+		name string
+		html string
+		// want lists each poll as id|title|deadline|votes|option=count,...
+		want []string
+	}{
+		{
+			name: "options and counts",
+			html: `<div class="poll" data-id="p1"><h3 class="poll-title"> Lunch? </h3>
+				<p class="poll-deadline">截止时间：2024-03-05 18:00</p>
+				<ul>` + option("noodles", "12票") + option("rice", "共 3 票") + `</ul></div>`,
+			want: []string{fmt.Sprintf("p1|Lunch?|%d|15|noodles=12,rice=3", deadline(2024, 3, 5, 18, 0))},
+		},
+		{
+			name: "vote markup with element id",
+			html: `<div class="vote-poll" id="vote-9"><div class="vote-title">Best</div>
+				<p class="vote-deadline">2024-12-31</p>
+				<div class="vote-option"><span class="vote-option-text">a</span><span class="vote-option-count">(7)</span></div></div>`,
+			want: []string{fmt.Sprintf("vote-9|Best|%d|7|a=7", deadline(2024, 12, 31, 0, 0))},
+		},
+		{
+			name: "fallback poll id",
+			html: `<div class="poll"><ul>` + option("x", "1") + `</ul></div>
+				<div class="poll"><ul>` + option("y", "2") + `</ul></div>`,
+			want: []string{"t1-0||0|1|x=1", "t1-1||0|2|y=2"},
+		},
+		{
+			name: "missing counts and deadline",
+			html: `<div class="poll" data-id="p2"><p class="poll-deadline">soon</p><ul>` + option("x", "") + option("y", "no votes") + `</ul></div>`,
+			want: []string{"p2||0|0|x=0,y=0"},
+		},
+		{
+			name: "poll without options",
+			html: `<div class="poll" data-id="p3"><h3 class="poll-title">empty</h3></div>`,
+		},
+		{
+			name: "no poll",
+			html: `<p>just text</p>`,
+		},
+	}
+
+	d := testService(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := testDocument(t, `<div class="topic-content">`+tt.html+`</div><div class="poll" data-id="outside"><ul>`+option("z", "1")+`</ul></div>`)
+
+			var got []string
+			for _, poll := range d.extractPolls(doc, "t1") {
+				var options []string
+				for i, o := range poll.Options {
+					if o.Position != i || o.PollId != poll.PollId || o.TopicId != "t1" {
+						t.Errorf("option %d of %s = %+v", i, poll.PollId, o)
+					}
+					options = append(options, fmt.Sprintf("%s=%d", o.Title, o.VoteCount))
+				}
+				got = append(got, fmt.Sprintf("%s|%s|%d|%d|%s", poll.PollId, poll.Title, poll.Deadline, poll.VoteCount, strings.Join(options, ",")))
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("polls = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
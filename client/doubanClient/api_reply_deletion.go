@@ -0,0 +1,58 @@
+package doubanClient
+
+import (
+	"log"
+	"time"
+)
+
+// storedReplies returns the data cids of the stored replies of a topic with their deletion time
+func (d *DoubanApiService) storedReplies(topicId string) (map[string]int64, error) {
+	return d.store().Replies().Stored(topicId)
+}
+
+// reconcileReplies marks stored replies missing from a full crawl as deleted,
+// and restores deleted replies that are back
+func (d *DoubanApiService) reconcileReplies(tx Store, topicId string, stored map[string]int64, seen map[string]struct{}) error {
+	var deleted, restored []string
+	for cid, deletedAt := range stored {
+		_, present := seen[cid]
+		switch {
+		case !present && deletedAt == 0:
+			deleted = append(deleted, cid)
+		case present && deletedAt != 0:
+			restored = append(restored, cid)
+		}
+	}
+
+	if len(deleted) > 0 {
+		if err := tx.Replies().SetDeleted(topicId, deleted, time.Now().Unix()); err != nil {
+			return err
+		}
+		log.Printf("Marked %d replies of topic %s deleted", len(deleted), topicId)
+	}
+
+	if len(restored) > 0 {
+		if err := tx.Replies().SetDeleted(topicId, restored, 0); err != nil {
+			return err
+		}
+		log.Printf("Restored %d replies of topic %s", len(restored), topicId)
+	}
+
+	return nil
+}
+
+// DeletedReplies returns the replies of a topic found deleted within [from, to)
+func (d *DoubanApiService) DeletedReplies(topicId string, from, to time.Time) ([]Reply, error) {
+	return d.store().Replies().Deleted(topicId, from, to)
+}
+
+// ReplyDeletionCount is the number of replies of a topic found deleted within a time window
+type ReplyDeletionCount struct {
+	TopicId string `json:"topic_id"`
+	Count   int64  `json:"count"`
+}
+
+// ReplyDeletions counts the replies found deleted within [from, to) per topic, most deletions first
+func (d *DoubanApiService) ReplyDeletions(from, to time.Time) ([]ReplyDeletionCount, error) {
+	return d.store().Replies().DeletionCounts(from, to)
+}
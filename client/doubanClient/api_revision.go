@@ -0,0 +1,55 @@
+package doubanClient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+// recordRevision writes a revision when the crawled body differs from the stored one.
+// The first crawl of a topic is recorded too, so the history holds every version seen.
+func (d *DoubanApiService) recordRevision(tx Store, topicId string, crawled *Topic) error {
+	stored, err := tx.Topics().Get(topicId)
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.ContentHash == crawled.ContentHash {
+		return nil
+	}
+
+	if stored.ContentHash != "" {
+		log.Printf("Topic %s was edited", topicId)
+	}
+
+	return tx.Topics().AddRevision(&TopicRevision{
+		TopicId:     topicId,
+		Content:     crawled.Content,
+		ContentHTML: crawled.ContentHTML,
+		ContentHash: crawled.ContentHash,
+		CrawlTime:   time.Now().Unix(),
+	})
+}
+
+// markTopicDeleted records that a topic was removed from douban
+func (d *DoubanApiService) markTopicDeleted(topicId string) error {
+	moved, err := d.transitionTopic(topicId, TopicDeleted, &Topic{DeletedAt: time.Now().Unix()}, "deleted_at")
+	if err != nil {
+		return err
+	}
+
+	if moved {
+		log.Printf("Topic %s was deleted", topicId)
+	}
+	return nil
+}
+
+// TopicRevisions returns the recorded versions of a topic, oldest first
+func (d *DoubanApiService) TopicRevisions(topicId string) ([]TopicRevision, error) {
+	return d.store().Topics().Revisions(topicId)
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,210 @@
+package doubanClient
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// SearchKeywords searches every configured group for its keywords and crawls the topics found
+// like the ones on the discussion pages, tagging each with the keyword that matched
+func (d *DoubanApiService) SearchKeywords() error {
+	if err := d.SyncTables(); err != nil {
+		return err
+	}
+
+	for _, group := range d.client.cfg.Groups {
+		for _, keyword := range group.Keywords {
+			if err := d.SearchGroup(group, keyword); err != nil {
+				if d.isBlocking(err) {
+					return err
+				}
+				log.Printf("Failed to search group %s for %q: %v", group.ID, keyword, err)
+			}
+		}
+	}
+
+	return d.updateQueuedUsers()
+}
+
+// SearchGroup crawls the topics of a group matching keyword, up to the group's SearchPages result pages.
+// Topics found by search matched the keyword whatever their age, so all of their replies are kept.
+func (d *DoubanApiService) SearchGroup(group GroupConfig, keyword string) error {
+	seen := make(map[string]struct{})
+	var tasks []crawlTask
+	for page := 1; page <= group.SearchPages; page++ {
+		start := (page - 1) * 50
+		searchURL := fmt.Sprintf(d.client.cfg.API.SearchURL, group.ID, url.QueryEscape(keyword), strconv.Itoa(start))
+		results, err := d.parseSearch(searchURL, group.ID)
+		if err != nil {
+			if d.isBlocking(err) {
+				return err
+			}
+			return fmt.Errorf("failed to parse search results on page %d: %w", page, err)
+		}
+
+		// A page without rows that still links a next page is not the end of the results
+		if len(results.topics) == 0 && results.more {
+			return fmt.Errorf("search page %d of group %s has no results but links a next page", page, group.ID)
+		}
+
+		var fresh []*Topic
+		for _, topic := range results.topics {
+			if _, ok := seen[topic.TopicId]; !ok {
+				seen[topic.TopicId] = struct{}{}
+				fresh = append(fresh, topic)
+			}
+		}
+
+		if len(fresh) > 0 {
+			toCrawl, err := d.upsertTopics(fresh, 0)
+			if err != nil {
+				return fmt.Errorf("failed to insert topics from search page %d: %w", page, err)
+			}
+			tasks = append(tasks, toCrawl...)
+
+			if err := d.tagTopics(fresh, group.ID, keyword); err != nil {
+				return fmt.Errorf("failed to tag topics from search page %d: %w", page, err)
+			}
+
+			log.Printf("Successfully stored %d topics matching %q from page %d of group %s, %d need crawling", len(fresh), keyword, page, group.ID, len(toCrawl))
+		}
+
+		if !results.more {
+			break
+		}
+	}
+	return d.crawlTopics(tasks)
+}
+
+// TopicsByKeyword returns the topics found by searching for keyword, most recently found first
+func (d *DoubanApiService) TopicsByKeyword(keyword string) ([]*Topic, error) {
+	if !d.hasMysql() {
+		return nil, ErrNoMysql
+	}
+
+	var tags []*TopicKeyword
+	if err := d.client.MysqlClient.Where("keyword = ?", keyword).Desc("match_time").Find(&tags); err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		ids = append(ids, tag.TopicId)
+	}
+
+	var topics []*Topic
+	if err := d.client.MysqlClient.In("topic_id", ids).Find(&topics); err != nil {
+		return nil, err
+	}
+
+	order := make(map[string]int, len(ids))
+	for i, id := range ids {
+		order[id] = i
+	}
+	sorted := make([]*Topic, len(ids))
+	for _, topic := range topics {
+		sorted[order[topic.TopicId]] = topic
+	}
+
+	result := sorted[:0]
+	for _, topic := range sorted {
+		if topic != nil {
+			result = append(result, topic)
+		}
+	}
+	return result, nil
+}
+
+// searchPage is one page of search results
+type searchPage struct {
+	topics []*Topic
+	// more is set when the page links a next page of results
+	more bool
+}
+
+// parseSearch fetches a search result page. The page goes through the same page classification
+// and ban handling as any other, so a blocked or empty answer is not mistaken for the end of the results.
+func (d *DoubanApiService) parseSearch(url, groupId string) (*searchPage, error) {
+	doc, err := d.fetch(url)
+	if err != nil {
+		return nil, err
+	}
+
+	d.validateSelectors(PageKindSearch, url, doc)
+	return d.searchResults(doc, groupId), nil
+}
+
+// searchResults reads the topics and the next page link of a search result page
+func (d *DoubanApiService) searchResults(doc *goquery.Document, groupId string) *searchPage {
+	sel := d.client.cfg.Selectors.Search
+	page := &searchPage{more: doc.Find(sel.Next).Length() > 0}
+	doc.Find(sel.Row).Each(func(i int, s *goquery.Selection) {
+		link := s.Find(sel.Title).First()
+		topicURL, exists := link.Attr("href")
+		if !exists {
+			log.Printf("Failed to extract search result: topic URL not found")
+			return
+		}
+
+		title := strings.TrimSpace(link.AttrOr("title", ""))
+		if title == "" {
+			title = strings.TrimSpace(link.Text())
+		}
+
+		// The cell shows a short date, its title holds the full one
+		timeCell := s.Find(sel.Time)
+		lastReplyTime := strings.TrimSpace(timeCell.AttrOr("title", ""))
+		if lastReplyTime == "" {
+			lastReplyTime = strings.TrimSpace(timeCell.Text())
+		}
+
+		replyCount, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s.Find(sel.ReplyCount).Text()), "回应")))
+		if err != nil {
+			replyCount = 0
+		}
+
+		page.topics = append(page.topics, &Topic{
+			TopicId:       d.extractID(topicURL, "group/topic"),
+			TopicUrl:      topicURL,
+			Title:         title,
+			TopicStatus:   string(TopicNew),
+			GroupId:       groupId,
+			ReplyCount:    replyCount,
+			LastReplyTime: lastReplyTime,
+		})
+	})
+	return page
+}
+
+// tagTopics records that topics matched keyword, once per topic and keyword
+func (d *DoubanApiService) tagTopics(topics []*Topic, groupId, keyword string) error {
+	if !d.hasMysql() {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	for _, topic := range topics {
+		exists, err := d.client.MysqlClient.Where("topic_id = ? AND keyword = ?", topic.TopicId, keyword).Exist(&TopicKeyword{})
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		tag := &TopicKeyword{TopicId: topic.TopicId, GroupId: groupId, Keyword: keyword, MatchTime: now}
+		if _, err := d.client.MysqlClient.Insert(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
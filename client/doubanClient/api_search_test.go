@@ -0,0 +1,82 @@
+package doubanClient
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSearchResults(t *testing.T) {
+	row := func(link, timeCell, replies string) string {
+		return `<tr class="pl"><td class="td-subject">` + link + `</td>` + timeCell +
+			`<td class="td-reply"><span>` + replies + `</span></td></tr>`
+	}
+	const next = `<div class="paginator"><span class="next"><a href="?start=50">后页</a></span></div>`
+
+	tests := []struct {
+		name string
+		html string
+		// want lists each topic as id|title|last reply time|reply count
+		want     []string
+		wantMore bool
+	}{
+		{
+			name: "title attribute and reply suffix",
+			html: `<table class="olt">` + row(`<a href="https://www.douban.com/group/topic/101/" title="Full title">Full ti...</a>`,
+				`<td class="td-time" title="2024-03-05 18:00:00">03-05</td>`, "12回应") + `</table>`,
+			want: []string{"101|Full title|2024-03-05 18:00:00|12"},
+		},
+		{
+			name: "title text and spaced reply suffix",
+			html: `<table class="olt">` + row(`<a href="https://www.douban.com/group/topic/102/"> Short </a>`,
+				`<td class="td-time"> 2024-03-05 </td>`, " 3 回应 ") + `</table>`,
+			want: []string{"102|Short|2024-03-05|3"},
+		},
+		{
+			name: "plain and missing reply counts",
+			html: `<table class="olt">` + row(`<a href="https://www.douban.com/group/topic/103/">a</a>`, "", "7") +
+				row(`<a href="https://www.douban.com/group/topic/104/">b</a>`, "", "") + `</table>`,
+			want: []string{"103|a||7", "104|b||0"},
+		},
+		{
+			name: "row without link",
+			html: `<table class="olt">` + row(`<span>gone</span>`, "", "1回应") + `</table>`,
+		},
+		{
+			name:     "rows and a next link",
+			html:     `<table class="olt">` + row(`<a href="https://www.douban.com/group/topic/105/">c</a>`, "", "") + `</table>` + next,
+			want:     []string{"105|c||0"},
+			wantMore: true,
+		},
+		{
+			name:     "no rows but a next link",
+			html:     `<table class="olt"></table>` + next,
+			wantMore: true,
+		},
+		{
+			name: "no rows",
+			html: `<p>没有找到</p>`,
+		},
+	}
+
+	d := testService(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := d.searchResults(testDocument(t, tt.html), "g1")
+
+			var got []string
+			for _, topic := range page.topics {
+				if topic.GroupId != "g1" || topic.TopicStatus != string(TopicNew) {
+					t.Errorf("topic %s = %+v", topic.TopicId, topic)
+				}
+				got = append(got, fmt.Sprintf("%s|%s|%s|%d", topic.TopicId, topic.Title, topic.LastReplyTime, topic.ReplyCount))
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("topics = %q, want %q", got, tt.want)
+			}
+			if page.more != tt.wantMore {
+				t.Errorf("more = %v, want %v", page.more, tt.wantMore)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package doubanClient
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+const (
+	// rowOverhead approximates the bytes a row adds to an insert statement besides its long text columns
+	rowOverhead = 256
+	// maxChunkRows keeps the placeholders of a statement far from MySQL's limit of 65535
+	maxChunkRows = 1000
+)
+
+// storeTopicPage writes the topic body and new replies crawled from one page of a topic in a single transaction,
+// so a crash never leaves a topic body updated with only part of its replies. finish, when set, runs last in it.
+// Polls are recorded after the commit on a best effort basis, their history is no reason to lose the page.
+func (d *DoubanApiService) storeTopicPage(topicId string, detail *topicDetail, fresh []*Reply, finish func(tx Store) error) error {
+	crawlTime := time.Now()
+	err := d.store().WithTx(func(tx Store) error {
+		if detail != nil {
+			if err := d.storeTopicDetails(tx, topicId, detail); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Replies().Insert(fresh); err != nil {
+			return fmt.Errorf("failed to insert replies: %w", err)
+		}
+
+		if finish != nil {
+			return finish(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if detail != nil {
+		if err := d.storePolls(detail.polls, crawlTime); err != nil {
+			log.Printf("Failed to store polls of topic %s: %v", topicId, err)
+		}
+		d.client.users.add(detail.authorId)
+	}
+	return nil
+}
+
+// storeTopicDetails stores the body, author, creation time and media of a topic,
+// recording a revision when the body changed
+func (d *DoubanApiService) storeTopicDetails(tx Store, topicId string, detail *topicDetail) error {
+	updateTopic := &Topic{
+		Content:     detail.content,
+		ContentHTML: detail.rich.HTML,
+		ContentMd:   detail.rich.Markdown,
+		ContentHash: contentHash(detail.rich.HTML),
+		CreateTime:  detail.createTime.Unix(),
+	}
+
+	if err := d.recordRevision(tx, topicId, updateTopic); err != nil {
+		return fmt.Errorf("failed to record topic revision: %w", err)
+	}
+
+	updateTopic.TopicId = topicId
+	found, err := tx.Topics().Update(updateTopic, "content", "content_html", "content_md", "content_hash", "create_time")
+	if err != nil {
+		return fmt.Errorf("failed to update topic: %w", err)
+	}
+
+	if !found {
+		return fmt.Errorf("no topic updated, possibly topic_id not found: %s", topicId)
+	}
+
+	if err := d.fillTopicAuthor(tx, topicId, detail); err != nil {
+		return fmt.Errorf("failed to store topic author: %w", err)
+	}
+
+	if err := d.replaceTopicMedia(tx, topicId, detail.rich); err != nil {
+		return fmt.Errorf("failed to store topic media: %w", err)
+	}
+
+	return nil
+}
+
+// fillTopicAuthor sets the author of a topic stored without one, as topics found by search are
+func (d *DoubanApiService) fillTopicAuthor(tx Store, topicId string, detail *topicDetail) error {
+	if detail.authorId == "" {
+		return nil
+	}
+
+	topics := tx.Topics()
+	stored, err := topics.Get(topicId)
+	if err != nil || stored == nil || stored.UserId != "" {
+		return err
+	}
+
+	_, err = topics.Update(&Topic{TopicId: topicId, UserName: detail.authorName, UserId: detail.authorId, UserUrl: detail.authorURL},
+		"user_name", "user_id", "user_url")
+	return err
+}
+
+// replaceTopicMedia replaces the stored images and links of a topic body with the crawled ones
+func (d *DoubanApiService) replaceTopicMedia(tx Store, topicId string, rich richContent) error {
+	images := make([]*TopicImage, 0, len(rich.Images))
+	for i, src := range rich.Images {
+		images = append(images, &TopicImage{TopicId: topicId, Url: src, Position: i})
+	}
+
+	links := make([]*TopicLink, 0, len(rich.Links))
+	for i, href := range rich.Links {
+		links = append(links, &TopicLink{TopicId: topicId, Url: href, Kind: linkKind(href), Position: i})
+	}
+	return tx.Topics().ReplaceMedia(topicId, images, links)
+}
+
+// insertChunked inserts rows in as few statements as fit under maxBytes, estimating each row with size
+func insertChunked[T any](db xorm.Interface, rows []*T, maxBytes int, size func(*T) int) error {
+	for _, chunk := range chunkRows(rows, maxBytes, size) {
+		if _, err := db.Insert(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkRows splits rows into chunks of at most maxChunkRows whose estimated size stays under maxBytes.
+// A row larger than maxBytes gets a chunk of its own.
+func chunkRows[T any](rows []*T, maxBytes int, size func(*T) int) [][]*T {
+	var chunks [][]*T
+	start, bytes := 0, 0
+	for i, row := range rows {
+		n := size(row)
+		if i > start && (bytes+n > maxBytes || i-start >= maxChunkRows) {
+			chunks = append(chunks, rows[start:i])
+			start, bytes = i, 0
+		}
+		bytes += n
+	}
+
+	if start < len(rows) {
+		chunks = append(chunks, rows[start:])
+	}
+	return chunks
+}
+
+// replySize estimates the bytes a reply adds to an insert statement, escaping can double its text
+func replySize(reply *Reply) int {
+	return rowOverhead + 2*(len(reply.Content)+len(reply.QuoteContent)+len(reply.Username)+len(reply.QuoteUserName))
+}
+
+func imageSize(image *TopicImage) int {
+	return rowOverhead + len(image.Url)
+}
@@ -0,0 +1,65 @@
+package doubanClient
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestChunkRows(t *testing.T) {
+	sized := func(sizes ...int) []*int {
+		rows := make([]*int, len(sizes))
+		for i := range sizes {
+			rows[i] = &sizes[i]
+		}
+		return rows
+	}
+	repeat := func(n, size int) []*int {
+		sizes := make([]int, n)
+		for i := range sizes {
+			sizes[i] = size
+		}
+		return sized(sizes...)
+	}
+
+	tests := []struct {
+		name     string
+		rows     []*int
+		maxBytes int
+		want     []int
+	}{
+		{"no rows", nil, 100, nil},
+		{"one row", sized(10), 100, []int{1}},
+		{"all fit", sized(10, 20, 30), 100, []int{3}},
+		{"exactly full", sized(50, 50), 100, []int{2}},
+		{"one byte over", sized(50, 51), 100, []int{1, 1}},
+		{"several chunks", sized(40, 40, 40, 40, 40), 100, []int{2, 2, 1}},
+		{"oversized row alone", sized(10, 500, 10), 100, []int{1, 1, 1}},
+		{"oversized first row", sized(500, 10, 10), 100, []int{1, 2}},
+		{"row limit", repeat(maxChunkRows+1, 1), 1 << 30, []int{maxChunkRows, 1}},
+		{"row limit twice", repeat(2*maxChunkRows, 1), 1 << 30, []int{maxChunkRows, maxChunkRows}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkRows(tt.rows, tt.maxBytes, func(n *int) int { return *n })
+
+			var got []int
+			total := 0
+			for _, chunk := range chunks {
+				got = append(got, len(chunk))
+				for _, row := range chunk {
+					if row != tt.rows[total] {
+						t.Fatalf("row %d is out of order", total)
+					}
+					total++
+				}
+			}
+			if total != len(tt.rows) {
+				t.Fatalf("chunks hold %d rows, want %d", total, len(tt.rows))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("chunk sizes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
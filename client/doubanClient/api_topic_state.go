@@ -0,0 +1,246 @@
+package doubanClient
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// TopicState is the lifecycle state of a topic, stored in Topic.TopicStatus
+type TopicState string
+
+const (
+	// TopicNew topics were listed but their replies were never crawled
+	TopicNew TopicState = "new"
+	// TopicCrawling topics are being crawled right now
+	TopicCrawling TopicState = "crawling"
+	// TopicSynced topics had all their replies crawled
+	TopicSynced TopicState = "synced"
+	// TopicStale topics got new replies since they were synced
+	TopicStale TopicState = "stale"
+	// TopicDeleted topics were removed from douban, this state is final
+	TopicDeleted TopicState = "deleted"
+	// TopicFailed topics failed their last crawl
+	TopicFailed TopicState = "failed"
+)
+
+// topicTransitions lists the states each state may move to.
+// A crawl stopped by a ban moves its topic back from crawling to the state it left.
+var topicTransitions = map[TopicState][]TopicState{
+	TopicNew:      {TopicCrawling, TopicDeleted},
+	TopicCrawling: {TopicSynced, TopicFailed, TopicDeleted, TopicNew, TopicStale},
+	TopicSynced:   {TopicStale, TopicCrawling, TopicDeleted},
+	TopicStale:    {TopicCrawling, TopicDeleted},
+	TopicFailed:   {TopicStale, TopicCrawling, TopicDeleted},
+}
+
+// legacyTopicStates maps the statuses written before the lifecycle states existed
+var legacyTopicStates = map[string]TopicState{
+	"unused": TopicNew,
+	"done":   TopicSynced,
+}
+
+// transitionSources returns the states that may move to the given state
+func transitionSources(to TopicState) []TopicState {
+	var sources []TopicState
+	for from, targets := range topicTransitions {
+		for _, target := range targets {
+			if target == to {
+				sources = append(sources, from)
+			}
+		}
+	}
+	return sources
+}
+
+// transitionTopic moves a topic to a state when its current state allows it, also writing cols of update.
+// It reports whether the topic moved.
+func (d *DoubanApiService) transitionTopic(topicId string, to TopicState, update *Topic, cols ...string) (bool, error) {
+	return d.transitionTopicIn(d.store(), topicId, to, update, cols...)
+}
+
+// transitionTopicIn is transitionTopic on tx, which lets the move join a transaction
+func (d *DoubanApiService) transitionTopicIn(tx Store, topicId string, to TopicState, update *Topic, cols ...string) (bool, error) {
+	update.TopicStatus = string(to)
+	update.StatusTime = time.Now().Unix()
+
+	moved, err := tx.Topics().Transition(topicId, transitionSources(to), update, to == TopicFailed, cols...)
+	if err != nil {
+		return false, fmt.Errorf("failed to move topic %s to %s: %w", topicId, to, err)
+	}
+	return moved, nil
+}
+
+// migrateTopicStates rewrites legacy statuses to lifecycle states
+func (d *DoubanApiService) migrateTopicStates() error {
+	for legacy, state := range legacyTopicStates {
+		if err := d.store().Topics().RenameState(legacy, state); err != nil {
+			return fmt.Errorf("failed to migrate topic status %s: %w", legacy, err)
+		}
+	}
+	return nil
+}
+
+// topicFlagCols are the topic columns only discussion pages know, updated along with the listed topics
+var topicFlagCols = []string{"sticky", "elite", "locked", "has_images"}
+
+// upsertTopics stores listed topics and returns the ones whose replies need crawling.
+// Existing topics get their title, counts and the extra cols updated.
+// A synced or failed topic whose reply count changed becomes stale.
+func (d *DoubanApiService) upsertTopics(topics []*Topic, interval time.Duration, cols ...string) ([]crawlTask, error) {
+	var toCrawl []crawlTask
+	for _, topic := range topics {
+		existing, err := d.store().Topics().Get(topic.TopicId)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing == nil {
+			topic.TopicStatus = string(TopicNew)
+			topic.StatusTime = time.Now().Unix()
+			if err := d.store().Topics().Insert(topic); err != nil {
+				return nil, err
+			}
+			toCrawl = append(toCrawl, crawlTask{topicId: topic.TopicId, interval: interval, growth: topic.ReplyCount})
+			continue
+		}
+
+		_, err = d.store().Topics().Update(topic, append([]string{"title", "reply_count", "last_reply_time"}, cols...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		state := TopicState(existing.TopicStatus)
+		if existing.ReplyCount != topic.ReplyCount && (state == TopicSynced || state == TopicFailed) {
+			moved, err := d.transitionTopic(topic.TopicId, TopicStale, &Topic{})
+			if err != nil {
+				return nil, err
+			}
+			if moved {
+				state = TopicStale
+			}
+		}
+
+		if state == TopicNew || state == TopicStale {
+			toCrawl = append(toCrawl, crawlTask{topicId: topic.TopicId, interval: interval, growth: topic.ReplyCount - existing.ReplyCount})
+		}
+	}
+	return toCrawl, nil
+}
+
+// crawlTopic crawls the replies of a topic, moving it through crawling to synced or failed.
+// A ban says nothing about the topic, so it moves back to the state it left without counting a failure.
+func (d *DoubanApiService) crawlTopic(topicId string, interval time.Duration) error {
+	topic, err := d.store().Topics().Get(topicId)
+	if err != nil {
+		return fmt.Errorf("failed to load topic %s: %w", topicId, err)
+	}
+	if topic == nil {
+		return nil
+	}
+	previous := TopicState(topic.TopicStatus)
+
+	moved, err := d.transitionTopic(topicId, TopicCrawling, &Topic{})
+	if err != nil {
+		return err
+	}
+	if !moved {
+		// Deleted, or already being crawled
+		return nil
+	}
+
+	// The topic turns synced in the transaction of its last page, so it is never synced with replies missing
+	deleted, crawlErr := d.updateRepliesByTopic(topicId, interval, func(tx Store) error {
+		update := &Topic{SyncTime: time.Now().Unix(), SyncedReplyCount: topic.ReplyCount}
+		_, err := d.transitionTopicIn(tx, topicId, TopicSynced, update, "sync_time", "fail_count", "synced_reply_count")
+		return err
+	})
+	switch {
+	case deleted:
+		return crawlErr
+	case PageStatusOf(crawlErr).IsBan():
+		update := &Topic{TopicStatus: string(previous), StatusTime: time.Now().Unix()}
+		if _, err := d.store().Topics().Transition(topicId, []TopicState{TopicCrawling}, update, false); err != nil {
+			log.Printf("Failed to move topic %s back to %s: %v", topicId, previous, err)
+		}
+		return crawlErr
+	case crawlErr != nil:
+		if _, err := d.transitionTopic(topicId, TopicFailed, &Topic{}); err != nil {
+			log.Printf("Failed to mark topic %s failed: %v", topicId, err)
+		}
+		return crawlErr
+	default:
+		return nil
+	}
+}
+
+// RecrawlTopics re-crawls a batch of topics by state: stale first, then failed ones below the failure limit,
+// then synced ones not crawled for longer than the maximum age, so edits and deletions are picked up
+func (d *DoubanApiService) RecrawlTopics() error {
+	tasks, err := d.recrawlTasks(time.Now())
+	if err != nil {
+		return err
+	}
+	return d.crawlTopics(tasks)
+}
+
+// recrawlTasks releases the topics of interrupted crawls and picks the batch RecrawlTopics crawls
+func (d *DoubanApiService) recrawlTasks(now time.Time) ([]crawlTask, error) {
+	cfg := d.client.cfg.Recrawl
+	topics := d.store().Topics()
+
+	// A crawl interrupted by a crash leaves its topic crawling, give it back to the scheduler
+	interrupted, err := topics.Find(TopicQuery{State: TopicCrawling, StatusBefore: now.Add(-cfg.CrawlingTimeout).Unix()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find interrupted topics: %w", err)
+	}
+	for _, topic := range interrupted {
+		update := &Topic{TopicStatus: string(TopicFailed), StatusTime: now.Unix()}
+		if _, err := topics.Transition(topic.TopicId, []TopicState{TopicCrawling}, update, true); err != nil {
+			return nil, fmt.Errorf("failed to release interrupted topic %s: %w", topic.TopicId, err)
+		}
+	}
+
+	groups := make(map[string]GroupConfig, len(d.client.cfg.Groups))
+	groupIds := make([]string, 0, len(d.client.cfg.Groups))
+	for _, group := range d.client.cfg.Groups {
+		groups[group.ID] = group
+		groupIds = append(groupIds, group.ID)
+	}
+	if len(groupIds) == 0 {
+		return nil, nil
+	}
+
+	queries := []TopicQuery{
+		{State: TopicStale},
+		{State: TopicSynced, SyncedBefore: now.Add(-cfg.MaxAge).Unix()},
+	}
+	if cfg.MaxFailures > 0 {
+		queries = append(queries[:1], TopicQuery{State: TopicFailed, MaxFailures: cfg.MaxFailures}, queries[1])
+	}
+
+	var picked []*Topic
+	for _, query := range queries {
+		if len(picked) >= cfg.Batch {
+			break
+		}
+
+		query.GroupIds = groupIds
+		query.Limit = cfg.Batch - len(picked)
+		batch, err := topics.Find(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select topics to re-crawl: %w", err)
+		}
+		picked = append(picked, batch...)
+	}
+
+	tasks := make([]crawlTask, 0, len(picked))
+	for _, topic := range picked {
+		tasks = append(tasks, crawlTask{
+			topicId:  topic.TopicId,
+			interval: d.groupInterval(groups[topic.GroupId]),
+			growth:   topic.ReplyCount - topic.SyncedReplyCount,
+		})
+	}
+	return tasks, nil
+}
@@ -0,0 +1,162 @@
+package doubanClient
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// userQueue collects the user ids seen while crawling, so their profiles can be fetched lazily
+type userQueue struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+	// limit caps the queued ids, zero does not
+	limit int
+}
+
+// add queues an id, unless the queue is full
+func (q *userQueue) add(id string) {
+	if id == "" {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ids == nil {
+		q.ids = make(map[string]struct{})
+	}
+	if q.limit > 0 && len(q.ids) >= q.limit {
+		return
+	}
+	q.ids[id] = struct{}{}
+}
+
+// pop removes and returns a queued id
+func (q *userQueue) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for id := range q.ids {
+		delete(q.ids, id)
+		return id, true
+	}
+	return "", false
+}
+
+// drain pops ids into fetch until n profiles were fetched or the queue is empty.
+// fetch reports whether it fetched the profile, the ids it skips do not count.
+func (q *userQueue) drain(n int, fetch func(id string) (bool, error)) error {
+	for fetched := 0; fetched < n; {
+		id, ok := q.pop()
+		if !ok {
+			return nil
+		}
+
+		done, err := fetch(id)
+		if err != nil {
+			return err
+		}
+		if done {
+			fetched++
+		}
+	}
+	return nil
+}
+
+// UpdateUser fetches the profile of a user and stores it
+func (d *DoubanApiService) UpdateUser(userId string) error {
+	if !d.hasMysql() {
+		return ErrNoMysql
+	}
+
+	user, err := d.parseUser(userId)
+	if PageStatusOf(err) == PageNotFound {
+		// Keep a row for deleted accounts so they are not fetched again before the refresh period
+		user, err = &DoubanUser{UserId: userId, UpdateTime: time.Now().Unix()}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	existing := &DoubanUser{}
+	found, err := d.client.MysqlClient.Where("user_id = ?", userId).Cols("id").Get(existing)
+	if err != nil {
+		return fmt.Errorf("failed to query user %s: %w", userId, err)
+	}
+
+	if found {
+		_, err = d.client.MysqlClient.ID(existing.Id).AllCols().Update(user)
+	} else {
+		_, err = d.client.MysqlClient.Insert(user)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store user %s: %w", userId, err)
+	}
+	return nil
+}
+
+// updateQueuedUsers fetches a batch of the queued users whose profile is missing or older than the refresh period.
+// Queued users with a fresh profile are dropped on the way without counting against the batch.
+func (d *DoubanApiService) updateQueuedUsers() error {
+	if !d.hasMysql() {
+		return nil
+	}
+
+	return d.client.users.drain(d.client.cfg.Client.UserBatch, func(userId string) (bool, error) {
+		existing := &DoubanUser{}
+		found, err := d.client.MysqlClient.Where("user_id = ?", userId).Cols("update_time").Get(existing)
+		if err != nil {
+			return false, fmt.Errorf("failed to query user %s: %w", userId, err)
+		}
+
+		if found && time.Since(time.Unix(existing.UpdateTime, 0)) < d.client.cfg.Client.UserRefresh {
+			return false, nil
+		}
+
+		if err := d.UpdateUser(userId); err != nil {
+			if d.isBlocking(err) {
+				return false, err
+			}
+			log.Printf("Failed to update user %s: %v", userId, err)
+		}
+		return true, nil
+	})
+}
+
+func (d *DoubanApiService) parseUser(userId string) (*DoubanUser, error) {
+	url := fmt.Sprintf(d.client.cfg.API.UserURL, userId)
+	doc, err := d.fetch(url)
+	if err != nil {
+		return nil, err
+	}
+
+	d.validateSelectors(PageKindUser, url, doc)
+
+	sel := d.client.cfg.Selectors.User
+	profile := doc.Find(sel.Profile)
+	title := profile.Find(sel.Name)
+	signature := strings.TrimSpace(title.Find(sel.Signature).Text())
+	name := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(title.Text()), signature))
+	if name == "" {
+		return nil, fmt.Errorf("user name not found for %s", userId)
+	}
+
+	user := &DoubanUser{
+		UserId:     userId,
+		Name:       name,
+		Signature:  signature,
+		Avatar:     profile.Find(sel.Avatar).AttrOr("src", ""),
+		Location:   strings.TrimSpace(doc.Find(sel.Location).First().Text()),
+		UpdateTime: time.Now().Unix(),
+	}
+
+	if date := datePattern.FindString(doc.Find(sel.JoinDate).Text()); date != "" {
+		if joined, err := time.ParseInLocation("2006-01-02", date, shanghai); err == nil {
+			user.JoinTime = joined.Unix()
+		}
+	}
+
+	return user, nil
+}
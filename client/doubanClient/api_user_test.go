@@ -0,0 +1,87 @@
+package doubanClient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestUserQueue(t *testing.T) {
+	q := &userQueue{limit: 3}
+	for _, id := range []string{"a", "", "b", "a", "c", "d"} {
+		q.add(id)
+	}
+
+	var got []string
+	for {
+		id, ok := q.pop()
+		if !ok {
+			break
+		}
+		got = append(got, id)
+	}
+	sort.Strings(got)
+	if fmt.Sprint(got) != "[a b c]" {
+		t.Errorf("queued ids = %v, want [a b c]", got)
+	}
+
+	q.add("e")
+	if id, ok := q.pop(); !ok || id != "e" {
+		t.Errorf("pop() = %q, %v after draining, want e", id, ok)
+	}
+}
+
+func TestUserQueueDrain(t *testing.T) {
+	failed := errors.New("blocked")
+
+	tests := []struct {
+		name    string
+		queued  int
+		fresh   int
+		n       int
+		failAt  int
+		fetched int
+		left    int
+		wantErr bool
+	}{
+		{"fresh ids do not count", 10, 6, 3, 0, 3, 1, false},
+		{"queue runs out", 4, 2, 3, 0, 2, 0, false},
+		{"all fresh", 5, 5, 3, 0, 0, 0, false},
+		{"error stops", 10, 0, 5, 2, 1, 8, true},
+		{"empty queue", 0, 0, 3, 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &userQueue{}
+			for i := 0; i < tt.queued; i++ {
+				q.add(fmt.Sprint(i))
+			}
+
+			// Ids pop in no particular order, so the first ones popped are the fresh ones
+			fetched, calls := 0, 0
+			err := q.drain(tt.n, func(id string) (bool, error) {
+				calls++
+				if calls == tt.failAt {
+					return false, failed
+				}
+				if calls <= tt.fresh {
+					return false, nil
+				}
+				fetched++
+				return true, nil
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("drain() = %v, want error %v", err, tt.wantErr)
+			}
+			if fetched != tt.fetched {
+				t.Errorf("fetched %d profiles, want %d", fetched, tt.fetched)
+			}
+			if left := len(q.ids); left != tt.left {
+				t.Errorf("%d ids left, want %d", left, tt.left)
+			}
+		})
+	}
+}
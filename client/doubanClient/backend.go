@@ -0,0 +1,119 @@
+package doubanClient
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// topicSource crawls the topics and replies of groups from one of the douban sites
+type topicSource interface {
+	// topics returns the recent topics of a group, starting at the start-th topic
+	topics(groupId string, start int, interval time.Duration) ([]*Topic, error)
+	// replies returns the page of a topic starting at the start-th reply, the first page with the topic body
+	replies(topicId string, start int) (*topicPage, error)
+}
+
+// topicPage is everything crawled from one page of a topic, stored in one transaction
+type topicPage struct {
+	// detail is the topic body, only set on the first page
+	detail *topicDetail
+	// replies are all replies extracted from the page, whatever their age
+	replies []*Reply
+	// cids are the ids of all replies on the page, whether or not they could be extracted,
+	// so reconciling never mistakes them for deleted
+	cids []string
+}
+
+// topicDetail is the body of a topic as found on its first page
+type topicDetail struct {
+	authorName string
+	authorId   string
+	authorURL  string
+	content    string
+	rich       richContent
+	createTime time.Time
+	polls      []*Poll
+}
+
+// desktopSource parses the html of www.douban.com
+type desktopSource struct {
+	d       *DoubanApiService
+	retries int
+}
+
+func (s desktopSource) topics(groupId string, start int, interval time.Duration) ([]*Topic, error) {
+	url := fmt.Sprintf(s.d.client.cfg.API.DiscussionURL, groupId, strconv.Itoa(start))
+	doc, err := s.d.fetchPage(url, s.retries)
+	if err != nil {
+		return nil, err
+	}
+	return s.d.parseTopic(doc, url, groupId, interval)
+}
+
+func (s desktopSource) replies(topicId string, start int) (*topicPage, error) {
+	url := fmt.Sprintf(s.d.client.cfg.API.TopicURL, topicId, strconv.Itoa(start))
+	doc, err := s.d.fetchPage(url, s.retries)
+	if err != nil {
+		return nil, err
+	}
+	if start == 0 && topicDeleted(doc, s.d.client.cfg.Selectors.Topic) {
+		return nil, &PageError{Status: PageNotFound, URL: url}
+	}
+	return s.d.parseReplies(doc, url, topicId, start == 0)
+}
+
+// listTopics returns the recent topics of a group from the configured backend
+func (d *DoubanApiService) listTopics(groupId string, start int, interval time.Duration) ([]*Topic, error) {
+	var topics []*Topic
+	err := d.withSource(func(s topicSource) error {
+		var err error
+		topics, err = s.topics(groupId, start, interval)
+		return err
+	})
+	return topics, err
+}
+
+// listReplies returns a topic page from the configured backend
+func (d *DoubanApiService) listReplies(topicId string, start int) (*topicPage, error) {
+	var page *topicPage
+	err := d.withSource(func(s topicSource) error {
+		var err error
+		page, err = s.replies(topicId, start)
+		return err
+	})
+	return page, err
+}
+
+// withSource calls fn with the configured backend. In auto mode the desktop site is tried first without
+// retries, and the mobile site takes over while the desktop site blocks us.
+func (d *DoubanApiService) withSource(fn func(topicSource) error) error {
+	switch d.client.cfg.Client.Backend {
+	case BackendMobile:
+		return fn(mobileSource{d})
+	case BackendAuto:
+		if !d.client.bans.get(hostOf(d.client.cfg.API.DiscussionURL)).cooling() {
+			err := fn(desktopSource{d: d})
+			if !PageStatusOf(err).IsBan() {
+				return err
+			}
+			log.Printf("Desktop site blocked, failing over to mobile site: %v", err)
+		}
+		return fn(mobileSource{d})
+	default:
+		return fn(desktopSource{d: d, retries: d.client.cfg.Ban.Retries})
+	}
+}
+
+// isBlocking reports whether err is a ban of the site topics are crawled from, so the crawl should stop.
+// Bans of the desktop site do not stop a crawl that can use the mobile site.
+func (d *DoubanApiService) isBlocking(err error) bool {
+	var pageErr *PageError
+	if !errors.As(err, &pageErr) || !pageErr.Status.IsBan() {
+		return false
+	}
+	mobile := hostOf(pageErr.URL) == hostOf(d.client.cfg.API.MobileTopicURL)
+	return mobile == (d.client.cfg.Client.Backend == BackendMobile || d.client.cfg.Client.Backend == BackendAuto)
+}
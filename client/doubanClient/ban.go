@@ -0,0 +1,210 @@
+package doubanClient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PageStatus classifies a douban response
+type PageStatus int
+
+const (
+	PageOK PageStatus = iota
+	PageCaptcha
+	PageForbidden
+	PageNotFound
+	PageLoginRequired
+)
+
+func (s PageStatus) String() string {
+	switch s {
+	case PageOK:
+		return "ok"
+	case PageCaptcha:
+		return "captcha"
+	case PageForbidden:
+		return "forbidden"
+	case PageNotFound:
+		return "not found"
+	case PageLoginRequired:
+		return "login required"
+	default:
+		return fmt.Sprintf("PageStatus(%d)", int(s))
+	}
+}
+
+// IsBan reports whether the status means douban is blocking the crawler
+func (s PageStatus) IsBan() bool {
+	return s == PageCaptcha || s == PageForbidden
+}
+
+// PageError is returned for any response that is not a normal page
+type PageError struct {
+	Status PageStatus
+	URL    string
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("douban page %s: %s", e.URL, e.Status)
+}
+
+// PageStatusOf returns the page status carried by err, or PageOK when err is not a PageError
+func PageStatusOf(err error) PageStatus {
+	var pageErr *PageError
+	if errors.As(err, &pageErr) {
+		return pageErr.Status
+	}
+	return PageOK
+}
+
+// Alert is raised whenever douban blocks the crawler and it pauses
+type Alert struct {
+	Status   PageStatus
+	URL      string
+	Strikes  int
+	Cooldown time.Duration
+	Time     time.Time
+}
+
+// classifyResponse decides what kind of response douban answered with from its redirects and status alone
+func classifyResponse(resp *http.Response) PageStatus {
+	finalURL := resp.Request.URL
+	switch {
+	case finalURL.Hostname() == "sec.douban.com":
+		return PageCaptcha
+	case finalURL.Hostname() == "accounts.douban.com" || strings.Contains(finalURL.Path, "/passport/login"):
+		return PageLoginRequired
+	case resp.StatusCode == http.StatusForbidden:
+		return PageForbidden
+	case resp.StatusCode == http.StatusNotFound:
+		return PageNotFound
+	}
+	return PageOK
+}
+
+// topicDeletedNotice is the text douban shows in place of a deleted topic
+const topicDeletedNotice = "该话题已被删除"
+
+// topicDeleted reports whether a topic page is the notice douban serves in place of a deleted topic.
+// The notice alone is not trusted, the topic body has to be missing as well.
+func topicDeleted(doc *goquery.Document, sel TopicSelectors) bool {
+	if doc.Find(sel.Body).Length() > 0 {
+		return false
+	}
+	return doc.Find(sel.Deleted).Length() > 0 || strings.Contains(doc.Find("#content").Text(), topicDeletedNotice)
+}
+
+// classifyPage decides what kind of page douban answered with
+func classifyPage(resp *http.Response, doc *goquery.Document, sel PageSelectors, expectLogin bool) PageStatus {
+	if status := classifyResponse(resp); status != PageOK {
+		return status
+	}
+
+	if doc.Find(sel.Captcha).Length() > 0 {
+		return PageCaptcha
+	}
+
+	title := strings.TrimSpace(doc.Find("title").Text())
+	switch {
+	case strings.Contains(title, "禁止访问"):
+		return PageForbidden
+	case strings.Contains(title, "页面不存在"):
+		return PageNotFound
+	case strings.Contains(title, "登录豆瓣"):
+		return PageLoginRequired
+	}
+
+	if expectLogin && doc.Find(sel.LoggedIn).Length() == 0 {
+		return PageLoginRequired
+	}
+	return PageOK
+}
+
+// banGuards keeps a banGuard per host, so a block of the desktop site does not pause the mobile site
+type banGuards struct {
+	mu     sync.Mutex
+	guards map[string]*banGuard
+}
+
+// get returns the guard of host, creating it on first use
+func (g *banGuards) get(host string) *banGuard {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.guards == nil {
+		g.guards = make(map[string]*banGuard)
+	}
+	guard, ok := g.guards[host]
+	if !ok {
+		guard = &banGuard{}
+		g.guards[host] = guard
+	}
+	return guard
+}
+
+// banGuard pauses all requests of a client to a host with exponential cool-down while douban blocks it
+type banGuard struct {
+	mu      sync.Mutex
+	strikes int
+	// started is when the last ban was counted, requests sent before it say nothing new
+	started time.Time
+	until   time.Time
+}
+
+// wait blocks until the current cool-down is over
+func (b *banGuard) wait() {
+	b.mu.Lock()
+	until := b.until
+	b.mu.Unlock()
+
+	if d := time.Until(until); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// cooling reports whether a cool-down is in progress
+func (b *banGuard) cooling() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.until)
+}
+
+// strike records a ban of a request sent at sent and returns the number of consecutive bans
+// and the cool-down before the next request. Requests already in flight when the last ban was counted
+// are banned by the same block, so they do not count again and it reports false.
+func (b *banGuard) strike(cfg BanConfig, sent time.Time) (int, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if sent.Before(b.started) {
+		return b.strikes, b.until.Sub(now), false
+	}
+
+	cooldown := cfg.Cooldown << b.strikes
+	if cooldown > cfg.MaxCooldown || cooldown <= 0 {
+		cooldown = cfg.MaxCooldown
+	}
+	b.strikes++
+	b.started = now
+	b.until = now.Add(cooldown)
+	return b.strikes, cooldown, true
+}
+
+// reset clears the strikes after a successful request sent at sent,
+// unless a ban was counted since it went out
+func (b *banGuard) reset(sent time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if sent.Before(b.started) {
+		return
+	}
+	b.strikes = 0
+}
@@ -0,0 +1,133 @@
+package doubanClient
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func testDocument(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func testResponse(t *testing.T, rawURL string, status int) *http.Response {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Response{StatusCode: status, Request: &http.Request{URL: u}}
+}
+
+func TestClassifyPage(t *testing.T) {
+	const (
+		topicURL = "https://www.douban.com/group/topic/1/"
+		loggedIn = `<div class="nav-user-account">me</div>`
+	)
+	sel := DefaultSelectorProfile().Page
+
+	tests := []struct {
+		name        string
+		url         string
+		status      int
+		html        string
+		expectLogin bool
+		want        PageStatus
+	}{
+		{"ok", topicURL, 200, `<title>话题</title><div id="content">ok</div>`, false, PageOK},
+		{"ok logged in", topicURL, 200, `<title>话题</title>` + loggedIn, true, PageOK},
+		{"captcha redirect", "https://sec.douban.com/c?r=1", 200, `<title>豆瓣</title>`, false, PageCaptcha},
+		{"captcha image", topicURL, 200, `<img id="captcha_image" src="x">`, false, PageCaptcha},
+		{"captcha input", topicURL, 200, `<input name="captcha-solution">`, false, PageCaptcha},
+		{"forbidden status", topicURL, 403, `<title>豆瓣</title>`, false, PageForbidden},
+		{"forbidden title", topicURL, 200, `<title>禁止访问</title>`, false, PageForbidden},
+		{"not found status", topicURL, 404, `<title>豆瓣</title>`, false, PageNotFound},
+		{"not found title", topicURL, 200, `<title>页面不存在</title>`, false, PageNotFound},
+		{"login redirect", "https://accounts.douban.com/passport/login?redir=x", 200, ``, false, PageLoginRequired},
+		{"login path", "https://www.douban.com/passport/login", 200, ``, false, PageLoginRequired},
+		{"login title", topicURL, 200, `<title>登录豆瓣</title>`, false, PageLoginRequired},
+		{"logged out session", topicURL, 200, `<title>话题</title>`, true, PageLoginRequired},
+		{"deletion text in a reply", topicURL, 200, `<title>话题</title><div id="content"><p>该话题已被删除 吗？</p></div>`, false, PageOK},
+		{"captcha before title", topicURL, 200, `<title>页面不存在</title><img id="captcha_image">`, false, PageCaptcha},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyPage(testResponse(t, tt.url, tt.status), testDocument(t, tt.html), sel, tt.expectLogin)
+			if got != tt.want {
+				t.Errorf("classifyPage() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopicDeleted(t *testing.T) {
+	sel := DefaultSelectorProfile().Topic
+
+	tests := []struct {
+		name string
+		html string
+		want bool
+	}{
+		{"topic", `<div id="link-report"><div><div>body</div></div></div>`, false},
+		{"notice", `<div id="content"><div class="topic-deleted">该话题已被删除</div></div>`, true},
+		{"notice with body", `<div id="content"><div class="deleted-tips">x</div><div id="link-report"><div><div>body</div></div></div></div>`, false},
+		{"notice text only", `<div id="content"><p>该话题已被删除</p></div>`, true},
+		{"notice text with body", `<div id="content"><p>该话题已被删除</p><div id="link-report"><div><div>body</div></div></div></div>`, false},
+		{"notice text outside content", `<div id="footer">该话题已被删除</div>`, false},
+		{"empty page", ``, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topicDeleted(testDocument(t, tt.html), sel); got != tt.want {
+				t.Errorf("topicDeleted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBanGuardStrike(t *testing.T) {
+	cfg := BanConfig{Cooldown: 5 * time.Minute, MaxCooldown: 15 * time.Minute}
+	guard := &banGuard{}
+
+	inFlight := time.Now()
+	strikes, cooldown, counted := guard.strike(cfg, inFlight)
+	if strikes != 1 || cooldown != 5*time.Minute || !counted {
+		t.Fatalf("first ban = %d, %s, %v, want 1, 5m, true", strikes, cooldown, counted)
+	}
+
+	// Requests sent along with the first one are banned by the same block
+	for i := 0; i < 3; i++ {
+		strikes, cooldown, counted = guard.strike(cfg, inFlight)
+		if strikes != 1 || counted || cooldown > 5*time.Minute {
+			t.Fatalf("in-flight ban %d = %d, %s, %v, want 1 strike not counted", i, strikes, cooldown, counted)
+		}
+	}
+	guard.reset(inFlight)
+	if guard.strikes != 1 {
+		t.Errorf("a success sent before the ban reset the strikes")
+	}
+
+	// Requests sent after the cool-down began count again, up to the maximum cool-down
+	for _, want := range []time.Duration{10 * time.Minute, 15 * time.Minute, 15 * time.Minute} {
+		strikes, cooldown, counted = guard.strike(cfg, time.Now())
+		if cooldown != want || !counted {
+			t.Fatalf("ban %d = %s, %v, want %s counted", strikes, cooldown, counted, want)
+		}
+	}
+
+	guard.reset(time.Now())
+	if guard.strikes != 0 {
+		t.Errorf("strikes = %d after a success, want 0", guard.strikes)
+	}
+}
@@ -1,6 +1,10 @@
 package doubanClient
 
 import (
+	"log"
+	"net/http"
+	"time"
+
 	"xorm.io/xorm"
 )
 
@@ -8,6 +12,22 @@ type APIClient struct {
 	cfg    *Configuration
 	common service // Reuse a single struct instead of allocating one for each service on the heap.
 
+	httpClient *http.Client
+	cookieJar  *CookieJar
+	bans       banGuards
+	limiters   limiters
+	schedule   groupSchedule
+	users      userQueue
+
+	// AlertHandler is called whenever douban blocks the crawler and it pauses
+	AlertHandler func(Alert)
+
+	// Store keeps topics and replies, on MysqlClient when unset
+	Store Store
+
+	// MysqlClient keeps groups, users, polls, keyword tags and snapshots.
+	// A client with only a Store crawls topics and replies without them,
+	// and the methods reading or writing them return ErrNoMysql.
 	MysqlClient      *xorm.Engine
 	DoubanServiceApi *DoubanApiService
 }
@@ -20,6 +40,25 @@ func NewAPIClient(cfg *Configuration) *APIClient {
 	c := &APIClient{}
 	c.cfg = cfg
 	c.common.client = c
+	c.users.limit = cfg.Client.UserQueue
+
+	// The single group of older configurations is crawled like any configured group
+	if id := cfg.Client.ID; id != "" && !cfg.hasGroup(id) {
+		cfg.WithID(id)
+	}
+
+	jar, err := NewCookieJar(cfg.Session.CookieFile)
+	if err != nil {
+		log.Printf("Failed to load cookie jar, starting without cookies: %v", err)
+		jar, _ = NewCookieJar("")
+	}
+	if cfg.Session.ImportFile != "" {
+		if err := jar.Import(cfg.Session.ImportFile); err != nil {
+			log.Printf("Failed to import cookies: %v", err)
+		}
+	}
+	c.cookieJar = jar
+	c.httpClient = &http.Client{Jar: jar, Timeout: time.Second * 30}
 
 	// API Services
 	c.DoubanServiceApi = (*DoubanApiService)(&c.common)
@@ -8,19 +8,108 @@ import (
 type APIConfig struct {
 	DiscussionURL string
 	TopicURL      string
+	GroupURL      string
+	UserURL       string
+	SearchURL     string
+
+	// Endpoints of the mobile site api used by the mobile backend
+	MobileTopicsURL   string
+	MobileTopicURL    string
+	MobileCommentsURL string
 }
 
+// Backend selects which douban site topics and replies are crawled from
+type Backend string
+
+const (
+	// BackendDesktop parses the html of www.douban.com
+	BackendDesktop Backend = "desktop"
+	// BackendMobile reads the json api behind m.douban.com, which carries no polls
+	BackendMobile Backend = "mobile"
+	// BackendAuto uses the desktop site and fails over to the mobile site while the desktop site blocks us
+	BackendAuto Backend = "auto"
+)
+
 // ClientConfig stores client-specific configuration
 type ClientConfig struct {
-	Header   map[string]string
-	ID       string
+	// ID is the single group crawled by older configurations.
+	//
+	// Deprecated: use Configuration.WithID or Groups instead.
+	ID           string
+	Header       map[string]string
+	MobileHeader map[string]string
+	Backend      Backend
+	Interval     time.Duration
+	// RequestDelay is the window of the request budget. Each host douban is crawled from
+	// (www.douban.com, m.douban.com) gets RequestBudget requests per window, used by all groups and workers.
+	RequestDelay time.Duration
+	// RequestBudget is how many requests to a host may be sent per RequestDelay,
+	// 1 spaces every request by the full delay
+	RequestBudget int
+	GroupRefresh  time.Duration
+	UserRefresh   time.Duration
+	UserBatch     int
+	// UserQueue caps the user ids waiting for their profile, ids seen while it is full are dropped
+	UserQueue int
+	// Workers is how many topics are crawled in parallel
+	Workers int
+	// MaxPacketSize bounds the size of a single insert statement, keep it under max_allowed_packet
+	MaxPacketSize int
+	// ValidateSelectors logs the selectors that match nothing on every parsed page
+	ValidateSelectors bool
+}
+
+// GroupConfig stores the crawl settings of a single group
+type GroupConfig struct {
+	ID string
+	// Interval is how far back topics and replies are kept, defaulting to ClientConfig.Interval
 	Interval time.Duration
+	// Schedule is the minimum time between two crawls of the group, zero crawls it on every run
+	Schedule time.Duration
+	// Pages is how many discussion pages are crawled
+	Pages int
+	// Priority orders the groups due in a run, higher first
+	Priority int
+	// Keywords are searched within the group by SearchKeywords
+	Keywords []string
+	// SearchPages is how many result pages are crawled per keyword
+	SearchPages int
+}
+
+// SessionConfig stores the cookie session configuration
+type SessionConfig struct {
+	CookieFile string
+	ImportFile string
+}
+
+// BanConfig stores how the client backs off when douban blocks it
+type BanConfig struct {
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+	Retries     int
+}
+
+// RecrawlConfig stores how topics are picked for re-crawling
+type RecrawlConfig struct {
+	// Batch is how many topics are re-crawled per run
+	Batch int
+	// MaxAge is how long a synced topic goes without a crawl before it is re-crawled
+	MaxAge time.Duration
+	// MaxFailures is how many failed crawls in a row a topic gets before it is given up
+	MaxFailures int
+	// CrawlingTimeout is how long a topic may stay crawling before the crawl counts as failed
+	CrawlingTimeout time.Duration
 }
 
 // Configuration stores the overall configuration of the API client
 type Configuration struct {
-	API    APIConfig
-	Client ClientConfig
+	API       APIConfig
+	Client    ClientConfig
+	Groups    []GroupConfig
+	Session   SessionConfig
+	Ban       BanConfig
+	Recrawl   RecrawlConfig
+	Selectors SelectorProfile
 }
 
 // DefaultHeader returns the default header configuration
@@ -33,24 +122,110 @@ func DefaultHeader() map[string]string {
 	}
 }
 
+// DefaultMobileHeader returns the default header for the mobile site api
+func DefaultMobileHeader() map[string]string {
+	return map[string]string{
+		"accept":          "application/json",
+		"accept-language": "zh-CN,zh;q=0.9",
+		"referer":         "https://m.douban.com/",
+		"user-agent":      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+	}
+}
+
 // NewConfiguration returns a new Configuration object with default values
 func NewConfiguration() *Configuration {
 	return &Configuration{
 		API: APIConfig{
 			DiscussionURL: "https://www.douban.com/group/%s/discussion?start=%s",
 			TopicURL:      "https://www.douban.com/group/topic/%s/?start=%s",
+			GroupURL:      "https://www.douban.com/group/%s/",
+			UserURL:       "https://www.douban.com/people/%s/",
+			SearchURL:     "https://www.douban.com/group/search?cat=1013&group=%s&q=%s&sort=time&start=%s",
+
+			MobileTopicsURL:   "https://m.douban.com/rexxar/api/v2/group/%s/topics?start=%s&count=50&sortby=new",
+			MobileTopicURL:    "https://m.douban.com/rexxar/api/v2/group/topic/%s",
+			MobileCommentsURL: "https://m.douban.com/rexxar/api/v2/group/topic/%s/comments?start=%s&count=100",
 		},
 		Client: ClientConfig{
-			Header:   DefaultHeader(),
-			ID:       "",
-			Interval: time.Hour * 24,
+			Header:       DefaultHeader(),
+			MobileHeader: DefaultMobileHeader(),
+			Backend:      BackendDesktop,
+			Interval:     time.Hour * 24,
+			RequestDelay: time.Minute * 2,
+			// One request every 15s on average, in bursts of up to 8 for the workers
+			RequestBudget: 8,
+			GroupRefresh:  time.Hour * 24,
+			UserRefresh:   time.Hour * 24 * 7,
+			UserBatch:     5,
+			UserQueue:     5000,
+			Workers:       4,
+			// MySQL 5.7's default max_allowed_packet, with some room
+			MaxPacketSize: 3 << 20,
+		},
+		Ban: BanConfig{
+			Cooldown:    time.Minute * 5,
+			MaxCooldown: time.Hour * 2,
+			Retries:     3,
+		},
+		Recrawl: RecrawlConfig{
+			Batch:           20,
+			MaxAge:          time.Hour * 24,
+			MaxFailures:     3,
+			CrawlingTimeout: time.Hour,
 		},
+		Selectors: DefaultSelectorProfile(),
 	}
 }
 
-// WithID sets the ID for the configuration
+// WithID adds a group crawled with the default settings
 func (c *Configuration) WithID(id string) *Configuration {
-	c.Client.ID = id
+	return c.WithGroup(GroupConfig{ID: id})
+}
+
+func (c *Configuration) hasGroup(id string) bool {
+	for _, group := range c.Groups {
+		if group.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// WithGroup adds a group, filling unset settings with the defaults
+func (c *Configuration) WithGroup(group GroupConfig) *Configuration {
+	if group.Pages <= 0 {
+		group.Pages = 1
+	}
+	if group.SearchPages <= 0 {
+		group.SearchPages = 5
+	}
+	c.Groups = append(c.Groups, group)
+	return c
+}
+
+// WithRequestDelay sets the minimum delay between two requests to a host, shared by all groups and workers
+func (c *Configuration) WithRequestDelay(delay time.Duration) *Configuration {
+	c.Client.RequestDelay = delay
+	c.Client.RequestBudget = 1
+	return c
+}
+
+// WithRequestBudget lets requests requests go to each host per window, shared by all groups and workers
+func (c *Configuration) WithRequestBudget(requests int, window time.Duration) *Configuration {
+	c.Client.RequestBudget = requests
+	c.Client.RequestDelay = window
+	return c
+}
+
+// WithBackend sets which douban site topics and replies are crawled from
+func (c *Configuration) WithBackend(backend Backend) *Configuration {
+	c.Client.Backend = backend
+	return c
+}
+
+// WithWorkers sets how many topics are crawled in parallel
+func (c *Configuration) WithWorkers(workers int) *Configuration {
+	c.Client.Workers = workers
 	return c
 }
 
@@ -60,6 +235,66 @@ func (c *Configuration) WithInterval(interval time.Duration) *Configuration {
 	return c
 }
 
+// WithGroupRefresh sets how often the group metadata is refreshed
+func (c *Configuration) WithGroupRefresh(refresh time.Duration) *Configuration {
+	c.Client.GroupRefresh = refresh
+	return c
+}
+
+// WithUserRefresh sets how long a crawled user profile is kept before it is fetched again,
+// and how many profiles are fetched at the end of a run, after all groups and re-crawls
+func (c *Configuration) WithUserRefresh(refresh time.Duration, batch int) *Configuration {
+	c.Client.UserRefresh = refresh
+	c.Client.UserBatch = batch
+	return c
+}
+
+// WithUserQueue caps how many user ids wait for their profile to be fetched
+func (c *Configuration) WithUserQueue(size int) *Configuration {
+	c.Client.UserQueue = size
+	return c
+}
+
+// WithCookieFile persists the session cookies to path between runs
+func (c *Configuration) WithCookieFile(path string) *Configuration {
+	c.Session.CookieFile = path
+	return c
+}
+
+// WithCookieImport imports the cookies of a browser export into the session
+func (c *Configuration) WithCookieImport(path string) *Configuration {
+	c.Session.ImportFile = path
+	return c
+}
+
+// WithBanCooldown sets the first cool-down after a ban, the cap it doubles up to and how often a blocked request is retried
+func (c *Configuration) WithBanCooldown(cooldown, maxCooldown time.Duration, retries int) *Configuration {
+	c.Ban = BanConfig{
+		Cooldown:    cooldown,
+		MaxCooldown: maxCooldown,
+		Retries:     retries,
+	}
+	return c
+}
+
+// WithRecrawl sets how topics are picked for re-crawling
+func (c *Configuration) WithRecrawl(recrawl RecrawlConfig) *Configuration {
+	c.Recrawl = recrawl
+	return c
+}
+
+// WithSelectors sets the selector profile used by the parsers
+func (c *Configuration) WithSelectors(profile SelectorProfile) *Configuration {
+	c.Selectors = profile
+	return c
+}
+
+// WithSelectorValidation logs the selectors that match nothing on every parsed page
+func (c *Configuration) WithSelectorValidation(enabled bool) *Configuration {
+	c.Client.ValidateSelectors = enabled
+	return c
+}
+
 // WithCustomHeader sets a custom header for the configuration
 func (c *Configuration) WithCustomHeader(key, value string) *Configuration {
 	c.Client.Header[key] = value
@@ -0,0 +1,315 @@
+package doubanClient
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	xhtml "golang.org/x/net/html"
+)
+
+// Link kinds recorded for outbound links
+const (
+	LinkWeibo    = "weibo"
+	LinkBilibili = "bilibili"
+	LinkPocket   = "pocket"
+	LinkOther    = "other"
+)
+
+// allowedTags are the elements kept by writeSanitized, with the attributes kept on each
+var allowedTags = map[string][]string{
+	"p":          nil,
+	"br":         nil,
+	"a":          {"href"},
+	"img":        {"src", "alt"},
+	"strong":     nil,
+	"b":          nil,
+	"em":         nil,
+	"i":          nil,
+	"ul":         nil,
+	"ol":         nil,
+	"li":         nil,
+	"blockquote": nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+}
+
+// richContent is the body of a topic or reply in every form we store
+type richContent struct {
+	HTML     string
+	Markdown string
+	Images   []string
+	Links    []string
+}
+
+func extractRichContent(s *goquery.Selection) richContent {
+	var c richContent
+	var sanitized, markdown strings.Builder
+	for _, n := range s.Nodes {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeSanitized(&sanitized, child)
+			writeMarkdown(&markdown, child)
+		}
+	}
+	c.HTML = strings.TrimSpace(sanitized.String())
+	c.Markdown = strings.TrimSpace(collapseBlankLines(markdown.String()))
+
+	c.Images = contentImages(s)
+	s.Find("a[href]").Each(func(i int, a *goquery.Selection) {
+		if href := outboundURL(a.AttrOr("href", "")); href != "" {
+			c.Links = append(c.Links, href)
+		}
+	})
+	return c
+}
+
+// contentImages returns the urls of the images in s
+func contentImages(s *goquery.Selection) []string {
+	var images []string
+	s.Find("img").Each(func(i int, img *goquery.Selection) {
+		if src := imageSource(img); src != "" {
+			images = append(images, src)
+		}
+	})
+	return images
+}
+
+// writeSanitized writes n keeping only allowed elements and attributes; other elements are unwrapped
+func writeSanitized(b *strings.Builder, n *xhtml.Node) {
+	switch n.Type {
+	case xhtml.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case xhtml.ElementNode:
+	default:
+		return
+	}
+
+	if n.Data == "script" || n.Data == "style" {
+		return
+	}
+
+	tag := n.Data
+	if tag == "div" {
+		// Douban wraps paragraphs in divs
+		tag = "p"
+	}
+
+	attrs, allowed := allowedTags[tag]
+	if allowed {
+		b.WriteString("<" + tag)
+		for _, name := range attrs {
+			value := attr(n, name)
+			switch name {
+			case "src":
+				value = safeURL(value)
+				if value == "" {
+					value = safeURL(attr(n, "data-src"))
+				}
+			case "href":
+				value = linkTarget(value)
+			}
+			if value != "" {
+				fmt.Fprintf(b, ` %s="%s"`, name, html.EscapeString(value))
+			}
+		}
+		b.WriteString(">")
+		if tag == "br" || tag == "img" {
+			return
+		}
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeSanitized(b, child)
+	}
+
+	if allowed {
+		b.WriteString("</" + tag + ">")
+	}
+}
+
+func writeMarkdown(b *strings.Builder, n *xhtml.Node) {
+	switch n.Type {
+	case xhtml.TextNode:
+		b.WriteString(markdownText(n.Data))
+		return
+	case xhtml.ElementNode:
+	default:
+		return
+	}
+
+	children := func() {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeMarkdown(b, child)
+		}
+	}
+
+	switch n.Data {
+	case "script", "style":
+	case "br":
+		b.WriteString("\n")
+	case "p", "div":
+		b.WriteString("\n\n")
+		children()
+		b.WriteString("\n\n")
+	case "h1", "h2", "h3":
+		b.WriteString("\n\n" + strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		children()
+		b.WriteString("\n\n")
+	case "strong", "b":
+		b.WriteString("**")
+		children()
+		b.WriteString("**")
+	case "em", "i":
+		b.WriteString("*")
+		children()
+		b.WriteString("*")
+	case "li":
+		b.WriteString("\n- ")
+		children()
+	case "blockquote":
+		var inner strings.Builder
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeMarkdown(&inner, child)
+		}
+		b.WriteString("\n\n")
+		for _, line := range strings.Split(strings.TrimSpace(collapseBlankLines(inner.String())), "\n") {
+			b.WriteString("> " + line + "\n")
+		}
+		b.WriteString("\n")
+	case "img":
+		src := safeURL(attr(n, "src"))
+		if src == "" {
+			src = safeURL(attr(n, "data-src"))
+		}
+		if src != "" {
+			fmt.Fprintf(b, "![%s](%s)", markdownText(attr(n, "alt")), markdownURL(src))
+		}
+	case "a":
+		var text strings.Builder
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeMarkdown(&text, child)
+		}
+		if href := linkTarget(attr(n, "href")); href != "" {
+			fmt.Fprintf(b, "[%s](%s)", strings.TrimSpace(text.String()), markdownURL(href))
+		} else {
+			b.WriteString(text.String())
+		}
+	default:
+		children()
+	}
+}
+
+// markdownText escapes the characters that let text read as a link or html in markdown
+var markdownText = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "<", "&lt;").Replace
+
+// markdownURL escapes the characters that would end a markdown link destination early
+var markdownURL = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20", "<", "%3C", ">", "%3E").Replace
+
+// linkTarget returns where a link leads once douban's redirect is resolved, or "" when that is not an http(s) url.
+// The redirect is resolved first, so a link2 wrapping a javascript: url is dropped rather than unwrapped into the page.
+func linkTarget(href string) string {
+	return safeURL(unwrapDoubanLink(strings.TrimSpace(href)))
+}
+
+// outboundURL returns the target of a link leaving douban, or "" for links within douban
+func outboundURL(href string) string {
+	href = linkTarget(href)
+	u, err := url.Parse(href)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	host := u.Hostname()
+	if host == "douban.com" || strings.HasSuffix(host, ".douban.com") || strings.HasSuffix(host, ".doubanio.com") {
+		return ""
+	}
+	return href
+}
+
+// unwrapDoubanLink resolves douban's link2 redirect to the real target
+func unwrapDoubanLink(href string) string {
+	u, err := url.Parse(href)
+	if err != nil || !hostIs(u.Hostname(), "douban.com") || !strings.HasPrefix(u.Path, "/link2") {
+		return href
+	}
+	if target := u.Query().Get("url"); target != "" {
+		return target
+	}
+	return href
+}
+
+// linkKind classifies an outbound link by the site it points to
+func linkKind(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return LinkOther
+	}
+	host := u.Hostname()
+	switch {
+	case hostIs(host, "weibo.com", "weibo.cn"):
+		return LinkWeibo
+	case hostIs(host, "bilibili.com", "b23.tv"):
+		return LinkBilibili
+	case hostIs(host, "48.cn"):
+		return LinkPocket
+	default:
+		return LinkOther
+	}
+}
+
+func hostIs(host string, domains ...string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func imageSource(img *goquery.Selection) string {
+	if src := safeURL(img.AttrOr("src", "")); src != "" {
+		return src
+	}
+	return safeURL(img.AttrOr("data-src", ""))
+}
+
+// safeURL drops everything but http(s) urls, so javascript: and data: urls never reach stored html
+func safeURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return raw
+}
+
+func attr(n *xhtml.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func collapseBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,161 @@
+package doubanClient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractRichContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		html     string
+		markdown string
+		links    []string
+	}{
+		{
+			name:     "paragraphs",
+			body:     `<div>first</div><div>second<br>line</div>`,
+			html:     `<p>first</p><p>second<br>line</p>`,
+			markdown: "first\n\nsecond\nline",
+		},
+		{
+			name:     "formatting",
+			body:     `<p><strong>bold</strong> <em>it</em> <span>plain</span></p><h2>title</h2>`,
+			html:     `<p><strong>bold</strong> <em>it</em> plain</p><h2>title</h2>`,
+			markdown: "**bold** *it* plain\n\n## title",
+		},
+		{
+			name:     "lists and quotes",
+			body:     `<ul><li>a</li><li>b</li></ul><blockquote><p>q1</p><p>q2</p></blockquote>`,
+			html:     `<ul><li>a</li><li>b</li></ul><blockquote><p>q1</p><p>q2</p></blockquote>`,
+			markdown: "- a\n- b\n\n> q1\n>\n> q2",
+		},
+		{
+			name:     "scripts and handlers",
+			body:     `<p onclick="x()">hi<script>alert(1)</script><style>p{}</style></p>`,
+			html:     `<p>hi</p>`,
+			markdown: "hi",
+		},
+		{
+			name:     "escaped text",
+			body:     `<p>&lt;b&gt; &amp; "q"</p>`,
+			html:     `<p>&lt;b&gt; &amp; &#34;q&#34;</p>`,
+			markdown: `&lt;b> & "q"`,
+		},
+		{
+			name:     "image",
+			body:     `<img src="https://img1.doubanio.com/a.jpg" alt="pic" onerror="x()">`,
+			html:     `<img src="https://img1.doubanio.com/a.jpg" alt="pic">`,
+			markdown: "![pic](https://img1.doubanio.com/a.jpg)",
+		},
+		{
+			name:     "lazy image",
+			body:     `<img src="data:image/gif;base64,R0lG" data-src="https://img1.doubanio.com/b.jpg">`,
+			html:     `<img src="https://img1.doubanio.com/b.jpg">`,
+			markdown: "![](https://img1.doubanio.com/b.jpg)",
+		},
+		{
+			name:     "link",
+			body:     `<a href="https://weibo.com/1" target="_blank">weibo</a>`,
+			html:     `<a href="https://weibo.com/1">weibo</a>`,
+			markdown: "[weibo](https://weibo.com/1)",
+			links:    []string{"https://weibo.com/1"},
+		},
+		{
+			name:     "douban redirect",
+			body:     `<a href="https://www.douban.com/link2/?url=https%3A%2F%2Fb23.tv%2Fx">b</a>`,
+			html:     `<a href="https://b23.tv/x">b</a>`,
+			markdown: "[b](https://b23.tv/x)",
+			links:    []string{"https://b23.tv/x"},
+		},
+		{
+			name:     "internal link",
+			body:     `<a href="https://www.douban.com/group/topic/1/">t</a>`,
+			html:     `<a href="https://www.douban.com/group/topic/1/">t</a>`,
+			markdown: "[t](https://www.douban.com/group/topic/1/)",
+		},
+		{
+			name:     "javascript link",
+			body:     `<a href="javascript:alert(1)">x</a>`,
+			html:     `<a>x</a>`,
+			markdown: "x",
+		},
+		{
+			name:     "javascript behind a douban redirect",
+			body:     `<a href="https://www.douban.com/link2/?url=javascript:alert(1)">x</a>`,
+			html:     `<a>x</a>`,
+			markdown: "x",
+		},
+		{
+			name:     "data url behind a douban redirect",
+			body:     `<a href="https://www.douban.com/link2/?url=data%3Atext%2Fhtml%2C%3Cscript%3E">x</a>`,
+			html:     `<a>x</a>`,
+			markdown: "x",
+		},
+		{
+			name:     "redirect on a lookalike host",
+			body:     `<a href="https://notdouban.com/link2/?url=https%3A%2F%2Fweibo.com%2F1">x</a>`,
+			html:     `<a href="https://notdouban.com/link2/?url=https%3A%2F%2Fweibo.com%2F1">x</a>`,
+			markdown: "[x](https://notdouban.com/link2/?url=https%3A%2F%2Fweibo.com%2F1)",
+			links:    []string{"https://notdouban.com/link2/?url=https%3A%2F%2Fweibo.com%2F1"},
+		},
+		{
+			name:     "link breaking out of markdown",
+			body:     `<a href="https://a.com/x)[y](javascript:alert(1)">z</a>`,
+			html:     `<a href="https://a.com/x)[y](javascript:alert(1)">z</a>`,
+			markdown: "[z](https://a.com/x%29[y]%28javascript:alert%281%29)",
+			links:    []string{"https://a.com/x)[y](javascript:alert(1)"},
+		},
+		{
+			name:     "markdown link in text",
+			body:     `<p>[click](javascript:alert(1))</p>`,
+			html:     `<p>[click](javascript:alert(1))</p>`,
+			markdown: `\[click\](javascript:alert(1))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := testDocument(t, `<div id="body">`+tt.body+`</div>`)
+			got := extractRichContent(doc.Find("#body"))
+
+			if got.HTML != tt.html {
+				t.Errorf("HTML = %q, want %q", got.HTML, tt.html)
+			}
+			if got.Markdown != tt.markdown {
+				t.Errorf("Markdown = %q, want %q", got.Markdown, tt.markdown)
+			}
+			if strings.Join(got.Links, " ") != strings.Join(tt.links, " ") {
+				t.Errorf("Links = %v, want %v", got.Links, tt.links)
+			}
+		})
+	}
+}
+
+func TestLinkTarget(t *testing.T) {
+	tests := []struct {
+		href string
+		want string
+	}{
+		{"https://weibo.com/1", "https://weibo.com/1"},
+		{"  http://a.com/  ", "http://a.com/"},
+		{"https://www.douban.com/link2/?url=https%3A%2F%2Fweibo.com%2F1", "https://weibo.com/1"},
+		{"https://douban.com/link2/?url=http://a.com", "http://a.com"},
+		{"https://www.douban.com/link2/", "https://www.douban.com/link2/"},
+		{"https://www.douban.com/link2/?url=javascript:alert(1)", ""},
+		{"https://www.douban.com/link2/?url=%20JavaScript:alert(1)", ""},
+		{"javascript:alert(1)", ""},
+		{"data:text/html,x", ""},
+		{"/group/topic/1/", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.href, func(t *testing.T) {
+			if got := linkTarget(tt.href); got != tt.want {
+				t.Errorf("linkTarget(%q) = %q, want %q", tt.href, got, tt.want)
+			}
+		})
+	}
+}
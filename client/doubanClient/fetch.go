@@ -0,0 +1,151 @@
+package doubanClient
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/tidwall/gjson"
+)
+
+// loginCookie is the cookie douban sets for a logged-in account
+const loginCookie = "dbcl2"
+
+// fetch requests a douban page through the session cookie jar and parses it.
+// Requests to a host draw on its request budget, shared by all groups and workers of the client.
+// When douban blocks the request, all requests of the client to that host pause with exponential cool-down
+// and the request is retried; a *PageError is returned for pages that are not normal content.
+func (d *DoubanApiService) fetch(url string) (*goquery.Document, error) {
+	return d.fetchPage(url, d.client.cfg.Ban.Retries)
+}
+
+// fetchPage is fetch with the number of retries after a ban
+func (d *DoubanApiService) fetchPage(url string, retries int) (*goquery.Document, error) {
+	var doc *goquery.Document
+	err := d.request(url, d.client.cfg.Client.Header, retries, func(resp *http.Response) (PageStatus, error) {
+		var err error
+		doc, err = goquery.NewDocumentFromReader(resp.Body)
+		if err != nil {
+			return PageOK, fmt.Errorf("failed to parse HTML: %w", err)
+		}
+
+		status := classifyPage(resp, doc, d.client.cfg.Selectors.Page, d.client.cookieJar.Has(loginCookie))
+		if status == PageOK && resp.StatusCode != http.StatusOK {
+			return PageOK, fmt.Errorf("unexpected status %d for %s", resp.StatusCode, url)
+		}
+		return status, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// fetchJSON requests a douban api endpoint the same way as fetch and returns the json body
+func (d *DoubanApiService) fetchJSON(url string) (gjson.Result, error) {
+	var body []byte
+	err := d.request(url, d.client.cfg.Client.MobileHeader, d.client.cfg.Ban.Retries, func(resp *http.Response) (PageStatus, error) {
+		var err error
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return PageOK, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		status := classifyResponse(resp)
+		if status != PageOK {
+			return status, nil
+		}
+		if !gjson.ValidBytes(body) {
+			// The anti-crawler check answers api requests with an html page
+			return PageCaptcha, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return PageOK, fmt.Errorf("unexpected status %d for %s: %s", resp.StatusCode, url, gjson.GetBytes(body, "msg").String())
+		}
+		return PageOK, nil
+	})
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	return gjson.ParseBytes(body), nil
+}
+
+// request sends a GET with header and hands the response to handle, which classifies it.
+// It holds the ban and delay logic shared by fetch and fetchJSON.
+func (d *DoubanApiService) request(rawURL string, header map[string]string, retries int, handle func(*http.Response) (PageStatus, error)) error {
+	host := hostOf(rawURL)
+	guard := d.client.bans.get(host)
+	for attempt := 0; ; attempt++ {
+		guard.wait()
+		d.client.limiters.get(host).wait(d.client.cfg.Client.RequestBudget, d.client.cfg.Client.RequestDelay)
+
+		sent := time.Now()
+		status, err := d.requestOnce(rawURL, header, handle)
+		if err != nil {
+			return err
+		}
+
+		if !status.IsBan() {
+			guard.reset(sent)
+			if status != PageOK {
+				return &PageError{Status: status, URL: rawURL}
+			}
+			return nil
+		}
+
+		if strikes, cooldown, counted := guard.strike(d.client.cfg.Ban, sent); counted {
+			d.alert(Alert{
+				Status:   status,
+				URL:      rawURL,
+				Strikes:  strikes,
+				Cooldown: cooldown,
+				Time:     time.Now(),
+			})
+		}
+
+		if attempt >= retries {
+			return &PageError{Status: status, URL: rawURL}
+		}
+	}
+}
+
+func (d *DoubanApiService) requestOnce(url string, header map[string]string, handle func(*http.Response) (PageStatus, error)) (PageStatus, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return PageOK, fmt.Errorf("failed to build request: %w", err)
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := d.client.httpClient.Do(req)
+	if err != nil {
+		return PageOK, fmt.Errorf("failed to request Douban page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := d.client.cookieJar.Save(); err != nil {
+		log.Printf("Failed to save cookie jar: %v", err)
+	}
+
+	return handle(resp)
+}
+
+func (d *DoubanApiService) alert(a Alert) {
+	log.Printf("Douban blocked %s (%s), pausing for %s after %d strikes", a.URL, a.Status, a.Cooldown, a.Strikes)
+	if d.client.AlertHandler != nil {
+		d.client.AlertHandler(a)
+	}
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
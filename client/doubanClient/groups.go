@@ -0,0 +1,128 @@
+package doubanClient
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// groupSchedule remembers when each group was last crawled
+type groupSchedule struct {
+	mu          sync.Mutex
+	lastCrawled map[string]time.Time
+	seeded      bool
+}
+
+// seed fills in the crawl times stored by earlier runs, keeping the ones of this run
+func (s *groupSchedule) seed(stored map[string]time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastCrawled == nil {
+		s.lastCrawled = make(map[string]time.Time)
+	}
+	for id, at := range stored {
+		if _, ok := s.lastCrawled[id]; !ok {
+			s.lastCrawled[id] = at
+		}
+	}
+	s.seeded = true
+}
+
+func (s *groupSchedule) isSeeded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seeded
+}
+
+// due returns the groups whose schedule has elapsed, highest priority first
+func (s *groupSchedule) due(groups []GroupConfig, now time.Time) []GroupConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []GroupConfig
+	for _, group := range groups {
+		last, ok := s.lastCrawled[group.ID]
+		if !ok || now.Sub(last) >= group.Schedule {
+			due = append(due, group)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].Priority > due[j].Priority
+	})
+	return due
+}
+
+func (s *groupSchedule) markCrawled(id string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastCrawled == nil {
+		s.lastCrawled = make(map[string]time.Time)
+	}
+	s.lastCrawled[id] = at
+}
+
+// limiters keeps a limiter per host, shared by every group and worker of a client,
+// so the desktop and mobile sites each get their own budget
+type limiters struct {
+	mu       sync.Mutex
+	limiters map[string]*limiter
+}
+
+// get returns the limiter of host, creating it on first use
+func (l *limiters) get(host string) *limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limiters == nil {
+		l.limiters = make(map[string]*limiter)
+	}
+	lim, ok := l.limiters[host]
+	if !ok {
+		lim = &limiter{}
+		l.limiters[host] = lim
+	}
+	return lim
+}
+
+// limiter is a token bucket holding the request budget of a host, shared by all groups and workers.
+// Up to budget requests go out at once, after that one more each window/budget.
+type limiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// wait blocks until a request of the budget is available and takes it.
+// A budget below one allows a single request per window.
+func (l *limiter) wait(budget int, window time.Duration) {
+	if window <= 0 {
+		return
+	}
+	if budget < 1 {
+		budget = 1
+	}
+	perToken := window / time.Duration(budget)
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.last.IsZero() {
+		l.tokens = float64(budget)
+	} else {
+		l.tokens = math.Min(float64(budget), l.tokens+float64(now.Sub(l.last))/float64(perToken))
+	}
+	l.last = now
+
+	// Taking the token up front, even into debt, keeps waiting requests in line
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens * float64(perToken))
+	}
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
@@ -0,0 +1,180 @@
+package doubanClient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/araddon/dateparse"
+	"github.com/tidwall/gjson"
+)
+
+// mobileSource reads the json api behind m.douban.com, which is blocked independently of the desktop site
+type mobileSource struct {
+	d *DoubanApiService
+}
+
+func (s mobileSource) topics(groupId string, start int, interval time.Duration) ([]*Topic, error) {
+	url := fmt.Sprintf(s.d.client.cfg.API.MobileTopicsURL, groupId, strconv.Itoa(start))
+	result, err := s.d.fetchJSON(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var topics []*Topic
+	for _, item := range result.Get("topics").Array() {
+		topic := mobileTopic(item, groupId)
+		if topic.TopicId == "" {
+			continue
+		}
+		if topic.Sticky || s.d.isRecentTime(topic.LastReplyTime, interval) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics, nil
+}
+
+func (s mobileSource) replies(topicId string, start int) (*topicPage, error) {
+	page := &topicPage{}
+	if start == 0 {
+		detail, err := s.topicDetails(topicId)
+		if err != nil {
+			return nil, err
+		}
+		page.detail = detail
+	}
+
+	url := fmt.Sprintf(s.d.client.cfg.API.MobileCommentsURL, topicId, strconv.Itoa(start))
+	result, err := s.d.fetchJSON(url)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range result.Get("comments").Array() {
+		reply := mobileReply(item, topicId)
+		if reply.DataCid == "" {
+			continue
+		}
+		page.cids = append(page.cids, reply.DataCid)
+		page.replies = append(page.replies, reply)
+	}
+	return page, nil
+}
+
+func (s mobileSource) topicDetails(topicId string) (*topicDetail, error) {
+	result, err := s.d.fetchJSON(fmt.Sprintf(s.d.client.cfg.API.MobileTopicURL, topicId))
+	if err != nil {
+		return nil, err
+	}
+	return mobileTopicDetail(result)
+}
+
+// mobileTopicDetail reads the body of a rexxar topic. The payload carries no poll counts,
+// so polls are left empty and their snapshots pause while the mobile backend is in use.
+func mobileTopicDetail(result gjson.Result) (*topicDetail, error) {
+	createTime, err := dateparse.ParseIn(result.Get("create_time").String(), shanghai)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse create time: %w", err)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(result.Get("content").String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse topic content: %w", err)
+	}
+	body := doc.Find("body")
+	rich := extractRichContent(body)
+
+	// Photos are listed separately and only referenced by placeholders in the content
+	known := make(map[string]bool, len(rich.Images))
+	for _, src := range rich.Images {
+		known[src] = true
+	}
+	for _, src := range mobilePhotos(result.Get("photos")) {
+		if !known[src] {
+			rich.Images = append(rich.Images, src)
+		}
+	}
+
+	author := result.Get("author")
+	return &topicDetail{
+		authorName: author.Get("name").String(),
+		authorId:   mobileUserID(author),
+		authorURL:  author.Get("url").String(),
+		content:    strings.TrimSpace(body.Text()),
+		rich:       rich,
+		createTime: createTime,
+	}, nil
+}
+
+func mobileTopic(item gjson.Result, groupId string) *Topic {
+	topicId := item.Get("id").String()
+	topicURL := item.Get("url").String()
+	if topicURL == "" && topicId != "" {
+		topicURL = fmt.Sprintf("https://www.douban.com/group/topic/%s/", topicId)
+	}
+	author := item.Get("author")
+	return &Topic{
+		TopicId:       topicId,
+		TopicUrl:      topicURL,
+		UserName:      author.Get("name").String(),
+		UserId:        mobileUserID(author),
+		UserUrl:       author.Get("url").String(),
+		Title:         strings.TrimSpace(item.Get("title").String()),
+		TopicStatus:   string(TopicNew),
+		GroupId:       groupId,
+		ReplyCount:    int(item.Get("comments_count").Int()),
+		LastReplyTime: item.Get("update_time").String(),
+		Sticky:        item.Get("is_sticky").Bool(),
+		Elite:         item.Get("is_elite").Bool(),
+		Locked:        item.Get("is_locked").Bool() || item.Get("is_comment_locked").Bool(),
+		HasImages:     len(item.Get("photos").Array()) > 0,
+	}
+}
+
+func mobileReply(item gjson.Result, topicId string) *Reply {
+	author := item.Get("author")
+	reply := &Reply{
+		TopicId:   topicId,
+		Username:  author.Get("name").String(),
+		UserId:    mobileUserID(author),
+		UserURL:   author.Get("url").String(),
+		Content:   strings.TrimSpace(item.Get("text").String()),
+		Time:      item.Get("create_time").String(),
+		IP:        item.Get("ip_location").String(),
+		DataCid:   item.Get("id").String(),
+		LikeCount: int(item.Get("vote_count").Int()),
+		Images:    mobilePhotos(item.Get("photos")),
+	}
+
+	if ref := item.Get("ref_comment"); ref.Exists() {
+		reply.QuoteCid = ref.Get("id").String()
+		reply.QuoteUserName = ref.Get("author.name").String()
+		reply.QuoteUserId = mobileUserID(ref.Get("author"))
+		reply.QuoteContent = strings.TrimSpace(ref.Get("text").String())
+	}
+	return reply
+}
+
+// mobileUserID returns the id used in the desktop profile url of an author
+func mobileUserID(author gjson.Result) string {
+	if uid := author.Get("uid").String(); uid != "" {
+		return uid
+	}
+	return author.Get("id").String()
+}
+
+func mobilePhotos(photos gjson.Result) []string {
+	var images []string
+	for _, photo := range photos.Array() {
+		src := photo.Get("image.large.url").String()
+		if src == "" {
+			src = photo.Get("image.normal.url").String()
+		}
+		if src = safeURL(src); src != "" {
+			images = append(images, src)
+		}
+	}
+	return images
+}
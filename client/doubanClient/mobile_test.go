@@ -0,0 +1,185 @@
+package doubanClient
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestMobileTopic(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Topic
+	}{
+		{
+			name: "uid author",
+			payload: `{"id":"101","url":"https://www.douban.com/group/topic/101/","title":" Hello ","comments_count":12,
+				"update_time":"2024-03-05 18:00:00","author":{"id":"1234","uid":"alice","name":"Alice","url":"https://www.douban.com/people/alice/"},
+				"photos":[{"image":{"large":{"url":"https://img.doubanio.com/a.jpg"}}}]}`,
+			want: Topic{TopicId: "101", TopicUrl: "https://www.douban.com/group/topic/101/", Title: "Hello", ReplyCount: 12,
+				LastReplyTime: "2024-03-05 18:00:00", UserId: "alice", UserName: "Alice", UserUrl: "https://www.douban.com/people/alice/",
+				HasImages: true},
+		},
+		{
+			name:    "numeric id author and default url",
+			payload: `{"id":"102","title":"t","author":{"id":"1234","name":"Bob"}}`,
+			want:    Topic{TopicId: "102", TopicUrl: "https://www.douban.com/group/topic/102/", Title: "t", UserId: "1234", UserName: "Bob"},
+		},
+		{
+			name:    "sticky and elite",
+			payload: `{"id":"103","is_sticky":true,"is_elite":true}`,
+			want:    Topic{TopicId: "103", TopicUrl: "https://www.douban.com/group/topic/103/", Sticky: true, Elite: true},
+		},
+		{
+			name:    "locked",
+			payload: `{"id":"104","is_locked":true}`,
+			want:    Topic{TopicId: "104", TopicUrl: "https://www.douban.com/group/topic/104/", Locked: true},
+		},
+		{
+			name:    "comments locked",
+			payload: `{"id":"105","is_comment_locked":true}`,
+			want:    Topic{TopicId: "105", TopicUrl: "https://www.douban.com/group/topic/105/", Locked: true},
+		},
+		{
+			name:    "no id",
+			payload: `{"title":"t"}`,
+			want:    Topic{Title: "t"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			want.GroupId = "g1"
+			want.TopicStatus = string(TopicNew)
+			if got := mobileTopic(gjson.Parse(tt.payload), "g1"); *got != want {
+				t.Errorf("mobileTopic() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestMobileReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Reply
+	}{
+		{
+			name: "plain reply",
+			payload: `{"id":"9001","text":" hi ","create_time":"2024-03-05 18:01:02","ip_location":"上海","vote_count":3,
+				"author":{"id":"1234","uid":"alice","name":"Alice","url":"https://www.douban.com/people/alice/"}}`,
+			want: Reply{DataCid: "9001", Content: "hi", Time: "2024-03-05 18:01:02", IP: "上海", LikeCount: 3,
+				UserId: "alice", Username: "Alice", UserURL: "https://www.douban.com/people/alice/"},
+		},
+		{
+			name: "quote",
+			payload: `{"id":"9002","text":"agreed","author":{"id":"1234","name":"Bob"},
+				"ref_comment":{"id":"9001","text":" hi ","author":{"id":"5678","uid":"alice","name":"Alice"}}}`,
+			want: Reply{DataCid: "9002", Content: "agreed", UserId: "1234", Username: "Bob",
+				QuoteCid: "9001", QuoteContent: "hi", QuoteUserId: "alice", QuoteUserName: "Alice"},
+		},
+		{
+			name:    "quote of numeric id author",
+			payload: `{"id":"9003","ref_comment":{"id":"9002","text":"agreed","author":{"id":"1234","name":"Bob"}}}`,
+			want:    Reply{DataCid: "9003", QuoteCid: "9002", QuoteContent: "agreed", QuoteUserId: "1234", QuoteUserName: "Bob"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			want.TopicId = "101"
+			got := mobileReply(gjson.Parse(tt.payload), "101")
+			if fmt.Sprintf("%+v", *got) != fmt.Sprintf("%+v", want) {
+				t.Errorf("mobileReply() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestMobileReplyPhotos(t *testing.T) {
+	payload := `{"id":"9004","photos":[
+		{"image":{"large":{"url":"https://img.doubanio.com/large.jpg"},"normal":{"url":"https://img.doubanio.com/normal.jpg"}}},
+		{"image":{"normal":{"url":"https://img.doubanio.com/only-normal.jpg"}}},
+		{"image":{"large":{"url":"javascript:alert(1)"}}}]}`
+
+	got := mobileReply(gjson.Parse(payload), "101").Images
+	want := []string{"https://img.doubanio.com/large.jpg", "https://img.doubanio.com/only-normal.jpg"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Images = %q, want %q", got, want)
+	}
+}
+
+func TestMobileTopicDetail(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		wantAuthor string
+		wantBody   string
+		wantImages []string
+		wantErr    bool
+	}{
+		{
+			name: "uid author",
+			payload: `{"create_time":"2024-03-05 18:00:00","content":"<p>hello</p>",
+				"author":{"id":"1234","uid":"alice","name":"Alice","url":"https://www.douban.com/people/alice/"}}`,
+			wantAuthor: "alice|Alice|https://www.douban.com/people/alice/",
+			wantBody:   "hello",
+		},
+		{
+			name:       "numeric id author",
+			payload:    `{"create_time":"2024-03-05 18:00:00","content":"<p>hello</p>","author":{"id":"1234","name":"Bob"}}`,
+			wantAuthor: "1234|Bob|",
+			wantBody:   "hello",
+		},
+		{
+			name: "photos merged into images",
+			payload: `{"create_time":"2024-03-05 18:00:00","author":{"id":"1234"},
+				"content":"<p>look</p><img src=\"https://img.doubanio.com/a.jpg\"><p>[图片1]</p>",
+				"photos":[{"image":{"large":{"url":"https://img.doubanio.com/a.jpg"}}},{"image":{"large":{"url":"https://img.doubanio.com/b.jpg"}}}]}`,
+			wantAuthor: "1234||",
+			wantBody:   "look[图片1]",
+			wantImages: []string{"https://img.doubanio.com/a.jpg", "https://img.doubanio.com/b.jpg"},
+		},
+		{
+			name:    "bad create time",
+			payload: `{"create_time":"someday","content":"<p>hello</p>"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detail, err := mobileTopicDetail(gjson.Parse(tt.payload))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("mobileTopicDetail() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if author := detail.authorId + "|" + detail.authorName + "|" + detail.authorURL; author != tt.wantAuthor {
+				t.Errorf("author = %q, want %q", author, tt.wantAuthor)
+			}
+			if detail.content != tt.wantBody {
+				t.Errorf("content = %q, want %q", detail.content, tt.wantBody)
+			}
+			if fmt.Sprint(detail.rich.Images) != fmt.Sprint(tt.wantImages) {
+				t.Errorf("images = %q, want %q", detail.rich.Images, tt.wantImages)
+			}
+			if want := time.Date(2024, 3, 5, 18, 0, 0, 0, shanghai); !detail.createTime.Equal(want) {
+				t.Errorf("createTime = %v, want %v", detail.createTime, want)
+			}
+			if detail.polls != nil {
+				t.Errorf("polls = %v, want none from the mobile api", detail.polls)
+			}
+		})
+	}
+}
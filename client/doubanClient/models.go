@@ -6,28 +6,163 @@ import (
 
 type Topic struct {
 	Id            int64  `xorm:"pk autoincr"`
-	TopicId       string `xorm:"varchar(255) notnull" json:"topic_id"`
+	TopicId       string `xorm:"varchar(255) notnull index" json:"topic_id"`
 	TopicUrl      string `xorm:"varchar(255) notnull" json:"topic_url"`
 	UserName      string `xorm:"varchar(255) notnull" json:"user_name"`
 	UserId        string `xorm:"varchar(255) notnull" json:"user_id"`
 	UserUrl       string `xorm:"varchar(255) notnull" json:"user_url"`
 	Title         string `xorm:"varchar(255) notnull" json:"title"`
 	GroupId       string `xorm:"varchar(255) notnull" json:"group_id"`
-	TopicStatus   string `xorm:"varchar(255) notnull" json:"topic_status"`
+	TopicStatus   string `xorm:"varchar(255) notnull index" json:"topic_status"`
 	Content       string `xorm:"longtext notnull" json:"content"`
+	ContentHTML   string `xorm:"'content_html' longtext notnull" json:"content_html"`
+	ContentMd     string `xorm:"longtext notnull" json:"content_md"`
 	ReplyCount    int    `xorm:"int notnull" json:"reply_count"`
 	CreateTime    int64  `xorm:"BigInt(20) notnull" json:"create_time"`
 	LastReplyTime string `xorm:"varchar(255) notnull" json:"last_reply_time"`
+	ContentHash   string `xorm:"varchar(64) notnull" json:"content_hash"`
+	DeletedAt     int64  `xorm:"BigInt(20) notnull" json:"deleted_at"`
+	StatusTime    int64  `xorm:"BigInt(20) notnull" json:"status_time"`
+	SyncTime      int64  `xorm:"BigInt(20) notnull" json:"sync_time"`
+	FailCount     int    `xorm:"int notnull" json:"fail_count"`
+	// SyncedReplyCount is the reply count when the topic was last synced
+	SyncedReplyCount int `xorm:"int notnull" json:"synced_reply_count"`
+
+	// Flags shown on the discussion page
+	Sticky    bool `xorm:"bool notnull" json:"sticky"`
+	Elite     bool `xorm:"bool notnull" json:"elite"`
+	Locked    bool `xorm:"bool notnull" json:"locked"`
+	HasImages bool `xorm:"bool notnull" json:"has_images"`
+}
+
+// TopicRevision is one version of a topic body, written whenever a re-crawl finds it changed
+type TopicRevision struct {
+	Id          int64  `xorm:"pk autoincr"`
+	TopicId     string `xorm:"varchar(255) notnull index" json:"topic_id"`
+	Content     string `xorm:"longtext notnull" json:"content"`
+	ContentHTML string `xorm:"'content_html' longtext notnull" json:"content_html"`
+	ContentHash string `xorm:"varchar(64) notnull" json:"content_hash"`
+	CrawlTime   int64  `xorm:"BigInt(20) notnull" json:"crawl_time"`
 }
 
 type Reply struct {
 	Id        int64  `xorm:"pk autoincr"`
 	TopicId   string `xorm:"varchar(255) notnull" json:"topic_id"`
 	Username  string `xorm:"varchar(255) notnull" json:"username"`
+	UserId    string `xorm:"varchar(255) notnull index" json:"user_id"`
 	UserURL   string `xorm:"varchar(255) notnull" json:"user_url"`
 	Content   string `xorm:"longtext notnull" json:"content"`
 	Time      string `xorm:"varchar(255) notnull" json:"time"`
 	IP        string `xorm:"varchar(255) notnull" json:"ip"`
 	DataCid   string `xorm:"varchar(255) notnull" json:"data_cid"`
 	LikeCount int    `xorm:"int notnull" json:"like_count"`
+
+	QuoteCid      string `xorm:"varchar(255) notnull index" json:"quote_cid"`
+	QuoteUserName string `xorm:"varchar(255) notnull" json:"quote_user_name"`
+	QuoteUserId   string `xorm:"varchar(255) notnull" json:"quote_user_id"`
+	QuoteContent  string `xorm:"text notnull" json:"quote_content"`
+	DeletedAt     int64  `xorm:"BigInt(20) notnull index" json:"deleted_at"`
+
+	Images []string `xorm:"-" json:"-"`
+}
+
+// TopicKeyword records that a topic was found by searching its group for a keyword
+type TopicKeyword struct {
+	Id        int64  `xorm:"pk autoincr"`
+	TopicId   string `xorm:"varchar(255) notnull unique(topic_keyword)" json:"topic_id"`
+	GroupId   string `xorm:"varchar(255) notnull" json:"group_id"`
+	Keyword   string `xorm:"varchar(255) notnull unique(topic_keyword) index" json:"keyword"`
+	MatchTime int64  `xorm:"BigInt(20) notnull" json:"match_time"`
+}
+
+// Poll is a douban poll embedded in a topic
+type Poll struct {
+	Id        int64  `xorm:"pk autoincr"`
+	TopicId   string `xorm:"varchar(255) notnull unique(topic_poll)" json:"topic_id"`
+	PollId    string `xorm:"varchar(255) notnull unique(topic_poll)" json:"poll_id"`
+	Title     string `xorm:"varchar(255) notnull" json:"title"`
+	Deadline  int64  `xorm:"BigInt(20) notnull" json:"deadline"`
+	VoteCount int    `xorm:"int notnull" json:"vote_count"`
+	CrawlTime int64  `xorm:"BigInt(20) notnull" json:"crawl_time"`
+
+	Options []*PollOption `xorm:"-" json:"options"`
+}
+
+// PollOption is the vote count of a poll option at one crawl, one row per option per crawl
+type PollOption struct {
+	Id        int64  `xorm:"pk autoincr"`
+	TopicId   string `xorm:"varchar(255) notnull" json:"topic_id"`
+	PollId    string `xorm:"varchar(255) notnull index(poll_crawl)" json:"poll_id"`
+	Position  int    `xorm:"int notnull" json:"position"`
+	Title     string `xorm:"varchar(255) notnull" json:"title"`
+	VoteCount int    `xorm:"int notnull" json:"vote_count"`
+	CrawlTime int64  `xorm:"BigInt(20) notnull index(poll_crawl)" json:"crawl_time"`
+}
+
+// TopicImage is an image in a topic body, or in a reply when DataCid is set
+type TopicImage struct {
+	Id       int64  `xorm:"pk autoincr"`
+	TopicId  string `xorm:"varchar(255) notnull index" json:"topic_id"`
+	DataCid  string `xorm:"varchar(255) notnull" json:"data_cid"`
+	Url      string `xorm:"varchar(1024) notnull" json:"url"`
+	Position int    `xorm:"int notnull" json:"position"`
+}
+
+// TopicLink is an outbound link in a topic body
+type TopicLink struct {
+	Id       int64  `xorm:"pk autoincr"`
+	TopicId  string `xorm:"varchar(255) notnull index" json:"topic_id"`
+	Url      string `xorm:"varchar(1024) notnull" json:"url"`
+	Kind     string `xorm:"varchar(32) notnull" json:"kind"`
+	Position int    `xorm:"int notnull" json:"position"`
+}
+
+type Group struct {
+	Id          int64    `xorm:"pk autoincr"`
+	GroupId     string   `xorm:"varchar(255) notnull unique" json:"group_id"`
+	Name        string   `xorm:"varchar(255) notnull" json:"name"`
+	MemberCount int      `xorm:"int notnull" json:"member_count"`
+	CreateTime  int64    `xorm:"BigInt(20) notnull" json:"create_time"`
+	Description string   `xorm:"longtext notnull" json:"description"`
+	Admins      []string `xorm:"text" json:"admins"`
+	Tags        []string `xorm:"text" json:"tags"`
+	UpdateTime  int64    `xorm:"BigInt(20) notnull" json:"update_time"`
+	// CrawlTime is when the topics of the group were last crawled, so restarts keep the schedule
+	CrawlTime int64 `xorm:"BigInt(20) notnull default 0" json:"crawl_time"`
+}
+
+type GroupMemberCount struct {
+	Id          int64  `xorm:"pk autoincr"`
+	GroupId     string `xorm:"varchar(255) notnull index" json:"group_id"`
+	MemberCount int    `xorm:"int notnull" json:"member_count"`
+	CrawlTime   int64  `xorm:"BigInt(20) notnull" json:"crawl_time"`
+}
+
+type DoubanUser struct {
+	Id         int64  `xorm:"pk autoincr"`
+	UserId     string `xorm:"varchar(255) notnull unique" json:"user_id"`
+	Name       string `xorm:"varchar(255) notnull" json:"name"`
+	Avatar     string `xorm:"varchar(255) notnull" json:"avatar"`
+	JoinTime   int64  `xorm:"BigInt(20) notnull" json:"join_time"`
+	Location   string `xorm:"varchar(255) notnull" json:"location"`
+	Signature  string `xorm:"text notnull" json:"signature"`
+	UpdateTime int64  `xorm:"BigInt(20) notnull" json:"update_time"`
+}
+
+// TopicSnapshot records the reply count of a topic at one crawl
+type TopicSnapshot struct {
+	Id         int64  `xorm:"pk autoincr"`
+	TopicId    string `xorm:"varchar(255) notnull index" json:"topic_id"`
+	GroupId    string `xorm:"varchar(255) notnull index" json:"group_id"`
+	ReplyCount int    `xorm:"int notnull" json:"reply_count"`
+	CrawlTime  int64  `xorm:"BigInt(20) notnull index" json:"crawl_time"`
+}
+
+// ReplySnapshot records the like count of a reply at one crawl
+type ReplySnapshot struct {
+	Id        int64  `xorm:"pk autoincr"`
+	TopicId   string `xorm:"varchar(255) notnull index" json:"topic_id"`
+	DataCid   string `xorm:"varchar(255) notnull" json:"data_cid"`
+	LikeCount int    `xorm:"int notnull" json:"like_count"`
+	CrawlTime int64  `xorm:"BigInt(20) notnull index" json:"crawl_time"`
 }
@@ -0,0 +1,84 @@
+package doubanClient
+
+import (
+	"errors"
+	"time"
+)
+
+// TopicRepository stores douban topics, their lifecycle state, body revisions and media
+type TopicRepository interface {
+	// Get returns the stored topic, or nil when there is none
+	Get(topicId string) (*Topic, error)
+	// Insert stores a new topic
+	Insert(topic *Topic) error
+	// Update writes cols of topic to the stored topic with the same TopicId and reports whether there was one
+	Update(topic *Topic, cols ...string) (bool, error)
+	// Transition moves a topic in one of the from states to update.TopicStatus, writing cols of update along,
+	// and increments its fail count when incrFailures is set. It reports whether the topic moved.
+	Transition(topicId string, from []TopicState, update *Topic, incrFailures bool, cols ...string) (bool, error)
+	// RenameState moves every topic with status from to state to
+	RenameState(from string, to TopicState) error
+	// Find returns the topics matching query, least recently moved first
+	Find(query TopicQuery) ([]*Topic, error)
+	// AddRevision stores a version of a topic body
+	AddRevision(revision *TopicRevision) error
+	// Revisions returns the stored versions of a topic body, oldest first
+	Revisions(topicId string) ([]TopicRevision, error)
+	// ReplaceMedia replaces the images and links of a topic body, reply images are kept
+	ReplaceMedia(topicId string, images []*TopicImage, links []*TopicLink) error
+}
+
+// TopicQuery selects topics for TopicRepository.Find, zero fields do not filter
+type TopicQuery struct {
+	State    TopicState
+	GroupIds []string
+	// MaxFailures keeps topics that failed fewer times
+	MaxFailures int
+	// StatusBefore keeps topics that moved to their state before this unix time
+	StatusBefore int64
+	// SyncedBefore keeps topics last synced before this unix time
+	SyncedBefore int64
+	Limit        int
+}
+
+// ReplyRepository stores the replies of douban topics
+type ReplyRepository interface {
+	// Stored returns the data cids of the stored replies of a topic with their deletion time
+	Stored(topicId string) (map[string]int64, error)
+	// Insert stores new replies with their images
+	Insert(replies []*Reply) error
+	// SetDeleted sets the deletion time of the replies of a topic with the given data cids, zero restores them
+	SetDeleted(topicId string, cids []string, deletedAt int64) error
+	// Deleted returns the replies of a topic found deleted within [from, to), oldest deletion first
+	Deleted(topicId string, from, to time.Time) ([]Reply, error)
+	// DeletionCounts counts the replies found deleted within [from, to) per topic, most deletions first
+	DeletionCounts(from, to time.Time) ([]ReplyDeletionCount, error)
+}
+
+// Store holds the topic and reply repositories of a crawler
+type Store interface {
+	Topics() TopicRepository
+	Replies() ReplyRepository
+	// WithTx runs fn on a store whose writes are kept when fn returns nil and dropped otherwise.
+	// Called on the store passed to fn, it runs fn in the same transaction.
+	WithTx(fn func(tx Store) error) error
+	// Sync creates or updates whatever the store keeps its data in
+	Sync() error
+}
+
+// store returns the store of the client, the sql one on MysqlClient when none is set
+func (d *DoubanApiService) store() Store {
+	if d.client.Store != nil {
+		return d.client.Store
+	}
+	return &SQLStore{engine: d.client.MysqlClient, MaxPacketSize: d.client.cfg.Client.MaxPacketSize}
+}
+
+// ErrNoMysql is returned for groups, users, polls, keyword tags and snapshots when MysqlClient is not set
+var ErrNoMysql = errors.New("doubanClient: MysqlClient is not set, only topics and replies are stored")
+
+// hasMysql reports whether MysqlClient is set. Groups, users, polls, keyword tags and snapshots
+// only live in MySQL, a crawl on a Store alone records topics and replies and skips them.
+func (d *DoubanApiService) hasMysql() bool {
+	return d.client.MysqlClient != nil
+}
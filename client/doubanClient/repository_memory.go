@@ -0,0 +1,432 @@
+package doubanClient
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"xorm.io/xorm/names"
+)
+
+// MemoryStore keeps topics and replies in memory, for tests and trying the crawler without a database.
+// A transaction works on a copy of the store and writes back the topics it touched when it commits,
+// so the last commit wins on a topic written by two transactions at once.
+type MemoryStore struct {
+	topics  *MemoryTopicRepository
+	replies *MemoryReplyRepository
+	// base is the store a transaction commits to
+	base *MemoryStore
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{topics: NewMemoryTopicRepository(), replies: NewMemoryReplyRepository()}
+}
+
+func (s *MemoryStore) Topics() TopicRepository {
+	return s.topics
+}
+
+func (s *MemoryStore) Replies() ReplyRepository {
+	return s.replies
+}
+
+func (s *MemoryStore) WithTx(fn func(tx Store) error) error {
+	if s.base != nil {
+		return fn(s)
+	}
+
+	tx := &MemoryStore{topics: s.topics.clone(), replies: s.replies.clone(), base: s}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.topics.commit(tx.topics)
+	s.replies.commit(tx.replies)
+	return nil
+}
+
+func (s *MemoryStore) Sync() error {
+	return nil
+}
+
+// MemoryTopicRepository keeps topics in memory, for tests
+type MemoryTopicRepository struct {
+	mu        sync.Mutex
+	ids       *int64
+	topics    map[string]*Topic
+	revisions map[string][]TopicRevision
+	images    map[string][]TopicImage
+	links     map[string][]TopicLink
+	// dirty holds the topics written by a transaction, nil outside of one
+	dirty map[string]bool
+}
+
+// NewMemoryTopicRepository returns an empty in-memory topic repository
+func NewMemoryTopicRepository() *MemoryTopicRepository {
+	return &MemoryTopicRepository{
+		ids:       new(int64),
+		topics:    make(map[string]*Topic),
+		revisions: make(map[string][]TopicRevision),
+		images:    make(map[string][]TopicImage),
+		links:     make(map[string][]TopicLink),
+	}
+}
+
+// clone copies the repository for a transaction, ids stay shared so commits never collide
+func (r *MemoryTopicRepository) clone() *MemoryTopicRepository {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := &MemoryTopicRepository{
+		ids:       r.ids,
+		topics:    make(map[string]*Topic, len(r.topics)),
+		revisions: make(map[string][]TopicRevision, len(r.revisions)),
+		images:    make(map[string][]TopicImage, len(r.images)),
+		links:     make(map[string][]TopicLink, len(r.links)),
+		dirty:     make(map[string]bool),
+	}
+	for id, topic := range r.topics {
+		copied := *topic
+		c.topics[id] = &copied
+	}
+	for id, revisions := range r.revisions {
+		c.revisions[id] = append([]TopicRevision(nil), revisions...)
+	}
+	for id, images := range r.images {
+		c.images[id] = append([]TopicImage(nil), images...)
+	}
+	for id, links := range r.links {
+		c.links[id] = append([]TopicLink(nil), links...)
+	}
+	return c
+}
+
+// commit writes the topics tx touched back to r
+func (r *MemoryTopicRepository) commit(tx *MemoryTopicRepository) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id := range tx.dirty {
+		if topic, ok := tx.topics[id]; ok {
+			r.topics[id] = topic
+		}
+		r.revisions[id] = tx.revisions[id]
+		r.images[id] = tx.images[id]
+		r.links[id] = tx.links[id]
+	}
+}
+
+// touch records that a transaction wrote a topic
+func (r *MemoryTopicRepository) touch(topicId string) {
+	if r.dirty != nil {
+		r.dirty[topicId] = true
+	}
+}
+
+func (r *MemoryTopicRepository) Get(topicId string) (*Topic, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	topic, ok := r.topics[topicId]
+	if !ok {
+		return nil, nil
+	}
+	copied := *topic
+	return &copied, nil
+}
+
+func (r *MemoryTopicRepository) Insert(topic *Topic) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	topic.Id = atomic.AddInt64(r.ids, 1)
+	copied := *topic
+	r.topics[topic.TopicId] = &copied
+	r.touch(topic.TopicId)
+	return nil
+}
+
+func (r *MemoryTopicRepository) Update(topic *Topic, cols ...string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.topics[topic.TopicId]
+	if !ok {
+		return false, nil
+	}
+	copyCols(stored, topic, cols)
+	r.touch(topic.TopicId)
+	return true, nil
+}
+
+func (r *MemoryTopicRepository) Transition(topicId string, from []TopicState, update *Topic, incrFailures bool, cols ...string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.topics[topicId]
+	if !ok {
+		return false, nil
+	}
+
+	allowed := false
+	for _, state := range from {
+		if stored.TopicStatus == string(state) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false, nil
+	}
+
+	copyCols(stored, update, append([]string{"topic_status", "status_time"}, cols...))
+	if incrFailures {
+		stored.FailCount++
+	}
+	r.touch(topicId)
+	return true, nil
+}
+
+func (r *MemoryTopicRepository) RenameState(from string, to TopicState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, topic := range r.topics {
+		if topic.TopicStatus == from {
+			topic.TopicStatus = string(to)
+			r.touch(topic.TopicId)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryTopicRepository) Find(query TopicQuery) ([]*Topic, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	groups := make(map[string]bool, len(query.GroupIds))
+	for _, id := range query.GroupIds {
+		groups[id] = true
+	}
+
+	var topics []*Topic
+	for _, topic := range r.topics {
+		switch {
+		case query.State != "" && topic.TopicStatus != string(query.State):
+		case len(groups) > 0 && !groups[topic.GroupId]:
+		case query.MaxFailures > 0 && topic.FailCount >= query.MaxFailures:
+		case query.StatusBefore > 0 && topic.StatusTime >= query.StatusBefore:
+		case query.SyncedBefore > 0 && topic.SyncTime >= query.SyncedBefore:
+		default:
+			copied := *topic
+			topics = append(topics, &copied)
+		}
+	}
+
+	sort.Slice(topics, func(i, j int) bool {
+		if topics[i].StatusTime != topics[j].StatusTime {
+			return topics[i].StatusTime < topics[j].StatusTime
+		}
+		return topics[i].Id < topics[j].Id
+	})
+	if query.Limit > 0 && len(topics) > query.Limit {
+		topics = topics[:query.Limit]
+	}
+	return topics, nil
+}
+
+func (r *MemoryTopicRepository) AddRevision(revision *TopicRevision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	revision.Id = atomic.AddInt64(r.ids, 1)
+	r.revisions[revision.TopicId] = append(r.revisions[revision.TopicId], *revision)
+	r.touch(revision.TopicId)
+	return nil
+}
+
+func (r *MemoryTopicRepository) Revisions(topicId string) ([]TopicRevision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	revisions := append([]TopicRevision(nil), r.revisions[topicId]...)
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].CrawlTime < revisions[j].CrawlTime
+	})
+	return revisions, nil
+}
+
+func (r *MemoryTopicRepository) ReplaceMedia(topicId string, images []*TopicImage, links []*TopicLink) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.images[topicId] = nil
+	for _, image := range images {
+		image.Id = atomic.AddInt64(r.ids, 1)
+		r.images[topicId] = append(r.images[topicId], *image)
+	}
+	r.links[topicId] = nil
+	for _, link := range links {
+		link.Id = atomic.AddInt64(r.ids, 1)
+		r.links[topicId] = append(r.links[topicId], *link)
+	}
+	r.touch(topicId)
+	return nil
+}
+
+// MemoryReplyRepository keeps replies in memory, for tests
+type MemoryReplyRepository struct {
+	mu      sync.Mutex
+	ids     *int64
+	replies map[string][]*Reply
+	// dirty holds the topics whose replies a transaction wrote, nil outside of one
+	dirty map[string]bool
+}
+
+// NewMemoryReplyRepository returns an empty in-memory reply repository
+func NewMemoryReplyRepository() *MemoryReplyRepository {
+	return &MemoryReplyRepository{ids: new(int64), replies: make(map[string][]*Reply)}
+}
+
+// clone copies the repository for a transaction, ids stay shared so commits never collide
+func (r *MemoryReplyRepository) clone() *MemoryReplyRepository {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := &MemoryReplyRepository{ids: r.ids, replies: make(map[string][]*Reply, len(r.replies)), dirty: make(map[string]bool)}
+	for id, replies := range r.replies {
+		for _, reply := range replies {
+			copied := *reply
+			c.replies[id] = append(c.replies[id], &copied)
+		}
+	}
+	return c
+}
+
+// commit writes the replies of the topics tx touched back to r
+func (r *MemoryReplyRepository) commit(tx *MemoryReplyRepository) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id := range tx.dirty {
+		r.replies[id] = tx.replies[id]
+	}
+}
+
+func (r *MemoryReplyRepository) Stored(topicId string) (map[string]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := make(map[string]int64, len(r.replies[topicId]))
+	for _, reply := range r.replies[topicId] {
+		stored[reply.DataCid] = reply.DeletedAt
+	}
+	return stored, nil
+}
+
+func (r *MemoryReplyRepository) Insert(replies []*Reply) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, reply := range replies {
+		reply.Id = atomic.AddInt64(r.ids, 1)
+		copied := *reply
+		r.replies[reply.TopicId] = append(r.replies[reply.TopicId], &copied)
+		if r.dirty != nil {
+			r.dirty[reply.TopicId] = true
+		}
+	}
+	return nil
+}
+
+func (r *MemoryReplyRepository) SetDeleted(topicId string, cids []string, deletedAt int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	set := make(map[string]bool, len(cids))
+	for _, cid := range cids {
+		set[cid] = true
+	}
+	for _, reply := range r.replies[topicId] {
+		if set[reply.DataCid] {
+			reply.DeletedAt = deletedAt
+		}
+	}
+	if r.dirty != nil {
+		r.dirty[topicId] = true
+	}
+	return nil
+}
+
+func (r *MemoryReplyRepository) Deleted(topicId string, from, to time.Time) ([]Reply, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var replies []Reply
+	for _, reply := range r.replies[topicId] {
+		if reply.DeletedAt >= from.Unix() && reply.DeletedAt < to.Unix() {
+			replies = append(replies, *reply)
+		}
+	}
+	sort.SliceStable(replies, func(i, j int) bool {
+		return replies[i].DeletedAt < replies[j].DeletedAt
+	})
+	return replies, nil
+}
+
+func (r *MemoryReplyRepository) DeletionCounts(from, to time.Time) ([]ReplyDeletionCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var counts []ReplyDeletionCount
+	for topicId, replies := range r.replies {
+		var count int64
+		for _, reply := range replies {
+			if reply.DeletedAt >= from.Unix() && reply.DeletedAt < to.Unix() {
+				count++
+			}
+		}
+		if count > 0 {
+			counts = append(counts, ReplyDeletionCount{TopicId: topicId, Count: count})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].TopicId < counts[j].TopicId
+	})
+	return counts, nil
+}
+
+// copyCols copies the fields of src named by cols to dst, both pointers to the same struct type,
+// matching column names the way xorm maps field names. Without cols every non-zero field is copied,
+// as xorm updates do.
+func copyCols(dst, src interface{}, cols []string) {
+	want := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		want[col] = true
+	}
+
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src).Elem()
+	for i := 0; i < sv.NumField(); i++ {
+		field := sv.Type().Field(i)
+		if field.Name == "Id" || !field.IsExported() {
+			continue
+		}
+
+		value := sv.Field(i)
+		if len(cols) == 0 {
+			if !value.IsZero() {
+				dv.Field(i).Set(value)
+			}
+			continue
+		}
+		if want[names.GonicMapper{}.Obj2Table(field.Name)] || want[names.SnakeMapper{}.Obj2Table(field.Name)] {
+			dv.Field(i).Set(value)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package doubanClient
+
+import (
+	"fmt"
+	"time"
+
+	"xorm.io/builder"
+	"xorm.io/xorm"
+)
+
+// defaultMaxPacketSize is used by repositories not built from a Configuration
+const defaultMaxPacketSize = 3 << 20
+
+// SQLStore keeps topics and replies through xorm, in MySQL or SQLite
+type SQLStore struct {
+	engine *xorm.Engine
+	// session is the transaction of a store passed to WithTx
+	session *xorm.Session
+	// MaxPacketSize bounds the size of a single insert statement
+	MaxPacketSize int
+}
+
+// NewSQLStore returns a store on engine
+func NewSQLStore(engine *xorm.Engine) *SQLStore {
+	return &SQLStore{engine: engine, MaxPacketSize: defaultMaxPacketSize}
+}
+
+func (s *SQLStore) db() xorm.Interface {
+	if s.session != nil {
+		return s.session
+	}
+	return s.engine
+}
+
+func (s *SQLStore) Topics() TopicRepository {
+	return &SQLTopicRepository{db: s.db(), MaxPacketSize: s.MaxPacketSize}
+}
+
+func (s *SQLStore) Replies() ReplyRepository {
+	return &SQLReplyRepository{db: s.db(), MaxPacketSize: s.MaxPacketSize}
+}
+
+func (s *SQLStore) WithTx(fn func(tx Store) error) error {
+	if s.session != nil {
+		return fn(s)
+	}
+
+	_, err := s.engine.Transaction(func(session *xorm.Session) (interface{}, error) {
+		return nil, fn(&SQLStore{engine: s.engine, session: session, MaxPacketSize: s.MaxPacketSize})
+	})
+	return err
+}
+
+func (s *SQLStore) Sync() error {
+	if err := s.engine.Sync2(Topic{}, TopicRevision{}, TopicImage{}, TopicLink{}); err != nil {
+		return fmt.Errorf("failed to sync topic tables: %w", err)
+	}
+	if err := s.engine.Sync2(Reply{}); err != nil {
+		return fmt.Errorf("failed to sync Reply table: %w", err)
+	}
+	return nil
+}
+
+// SQLTopicRepository stores topics through xorm, in MySQL or SQLite
+type SQLTopicRepository struct {
+	db xorm.Interface
+	// MaxPacketSize bounds the size of a single insert statement
+	MaxPacketSize int
+}
+
+// NewSQLTopicRepository returns a topic repository on an engine or a session
+func NewSQLTopicRepository(db xorm.Interface) *SQLTopicRepository {
+	return &SQLTopicRepository{db: db, MaxPacketSize: defaultMaxPacketSize}
+}
+
+func (r *SQLTopicRepository) Get(topicId string) (*Topic, error) {
+	topic := &Topic{}
+	found, err := r.db.Where("topic_id = ?", topicId).Get(topic)
+	if err != nil || !found {
+		return nil, err
+	}
+	return topic, nil
+}
+
+func (r *SQLTopicRepository) Insert(topic *Topic) error {
+	_, err := r.db.Insert(topic)
+	return err
+}
+
+func (r *SQLTopicRepository) Update(topic *Topic, cols ...string) (bool, error) {
+	affected, err := r.db.Where("topic_id = ?", topic.TopicId).Cols(cols...).Update(topic)
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+	// MySQL does not count rows written with the values they already had
+	return r.db.Where("topic_id = ?", topic.TopicId).Exist(&Topic{})
+}
+
+func (r *SQLTopicRepository) Transition(topicId string, from []TopicState, update *Topic, incrFailures bool, cols ...string) (bool, error) {
+	states := make([]string, 0, len(from))
+	for _, state := range from {
+		states = append(states, string(state))
+	}
+
+	session := r.db.Where("topic_id = ?", topicId).
+		In("topic_status", states).
+		Cols(append([]string{"topic_status", "status_time"}, cols...)...)
+	if incrFailures {
+		session = session.Incr("fail_count")
+	}
+
+	affected, err := session.Update(update)
+	return affected > 0, err
+}
+
+func (r *SQLTopicRepository) RenameState(from string, to TopicState) error {
+	_, err := r.db.Where("topic_status = ?", from).
+		Cols("topic_status").
+		Update(&Topic{TopicStatus: string(to)})
+	return err
+}
+
+func (r *SQLTopicRepository) Find(query TopicQuery) ([]*Topic, error) {
+	cond := builder.NewCond()
+	if query.State != "" {
+		cond = cond.And(builder.Eq{"topic_status": query.State})
+	}
+	if len(query.GroupIds) > 0 {
+		cond = cond.And(builder.In("group_id", query.GroupIds))
+	}
+	if query.MaxFailures > 0 {
+		cond = cond.And(builder.Lt{"fail_count": query.MaxFailures})
+	}
+	if query.StatusBefore > 0 {
+		cond = cond.And(builder.Lt{"status_time": query.StatusBefore})
+	}
+	if query.SyncedBefore > 0 {
+		cond = cond.And(builder.Lt{"sync_time": query.SyncedBefore})
+	}
+
+	session := r.db.Where(cond).Asc("status_time")
+	if query.Limit > 0 {
+		session = session.Limit(query.Limit)
+	}
+
+	var topics []*Topic
+	err := session.Find(&topics)
+	return topics, err
+}
+
+func (r *SQLTopicRepository) AddRevision(revision *TopicRevision) error {
+	_, err := r.db.Insert(revision)
+	return err
+}
+
+func (r *SQLTopicRepository) Revisions(topicId string) ([]TopicRevision, error) {
+	var revisions []TopicRevision
+	err := r.db.Where("topic_id = ?", topicId).Asc("crawl_time", "id").Find(&revisions)
+	return revisions, err
+}
+
+func (r *SQLTopicRepository) ReplaceMedia(topicId string, images []*TopicImage, links []*TopicLink) error {
+	if _, err := r.db.Where("topic_id = ? AND data_cid = ''", topicId).Delete(&TopicImage{}); err != nil {
+		return err
+	}
+	if _, err := r.db.Where("topic_id = ?", topicId).Delete(&TopicLink{}); err != nil {
+		return err
+	}
+
+	if err := insertChunked(r.db, images, r.MaxPacketSize, imageSize); err != nil {
+		return err
+	}
+	return insertChunked(r.db, links, r.MaxPacketSize, func(link *TopicLink) int {
+		return rowOverhead + len(link.Url)
+	})
+}
+
+// SQLReplyRepository stores replies through xorm, in MySQL or SQLite
+type SQLReplyRepository struct {
+	db xorm.Interface
+	// MaxPacketSize bounds the size of a single insert statement
+	MaxPacketSize int
+}
+
+// NewSQLReplyRepository returns a reply repository on an engine or a session
+func NewSQLReplyRepository(db xorm.Interface) *SQLReplyRepository {
+	return &SQLReplyRepository{db: db, MaxPacketSize: defaultMaxPacketSize}
+}
+
+func (r *SQLReplyRepository) Stored(topicId string) (map[string]int64, error) {
+	var replies []Reply
+	err := r.db.Where("topic_id = ?", topicId).Cols("data_cid", "deleted_at").Find(&replies)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(map[string]int64, len(replies))
+	for _, reply := range replies {
+		stored[reply.DataCid] = reply.DeletedAt
+	}
+	return stored, nil
+}
+
+func (r *SQLReplyRepository) Insert(replies []*Reply) error {
+	if err := insertChunked(r.db, replies, r.MaxPacketSize, replySize); err != nil {
+		return err
+	}
+
+	var images []*TopicImage
+	for _, reply := range replies {
+		for i, src := range reply.Images {
+			images = append(images, &TopicImage{TopicId: reply.TopicId, DataCid: reply.DataCid, Url: src, Position: i})
+		}
+	}
+	if err := insertChunked(r.db, images, r.MaxPacketSize, imageSize); err != nil {
+		return fmt.Errorf("failed to insert reply images: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLReplyRepository) SetDeleted(topicId string, cids []string, deletedAt int64) error {
+	if len(cids) == 0 {
+		return nil
+	}
+	_, err := r.db.Where("topic_id = ?", topicId).In("data_cid", cids).
+		Cols("deleted_at").
+		Update(&Reply{DeletedAt: deletedAt})
+	return err
+}
+
+func (r *SQLReplyRepository) Deleted(topicId string, from, to time.Time) ([]Reply, error) {
+	var replies []Reply
+	err := r.db.Where("topic_id = ? AND deleted_at >= ? AND deleted_at < ?", topicId, from.Unix(), to.Unix()).
+		Asc("deleted_at").
+		Find(&replies)
+	return replies, err
+}
+
+func (r *SQLReplyRepository) DeletionCounts(from, to time.Time) ([]ReplyDeletionCount, error) {
+	var counts []ReplyDeletionCount
+	err := r.db.Table(&Reply{}).
+		Select("topic_id, COUNT(*) AS count").
+		Where("deleted_at >= ? AND deleted_at < ?", from.Unix(), to.Unix()).
+		GroupBy("topic_id").
+		OrderBy("count DESC").
+		Find(&counts)
+	return counts, err
+}
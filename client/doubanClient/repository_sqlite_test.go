@@ -0,0 +1,45 @@
+//go:build sqlite
+
+package doubanClient
+
+import (
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"xorm.io/xorm"
+)
+
+func TestSQLStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		store := NewSQLStore(testSQLite(t))
+		if err := store.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestSyncTablesSQLite(t *testing.T) {
+	d := testService(nil, "g")
+	d.client.MysqlClient = testSQLite(t)
+
+	// Twice, as every run syncs tables that already exist
+	for i := 0; i < 2; i++ {
+		if err := d.SyncTables(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testSQLite opens a SQLite database in a temporary file, the way client.OpenSQLite does
+func testSQLite(t *testing.T) *xorm.Engine {
+	t.Helper()
+	engine, err := xorm.NewEngine("sqlite3", filepath.Join(t.TempDir(), "douban.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetMaxOpenConns(1)
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
@@ -0,0 +1,410 @@
+package doubanClient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewMemoryStore()
+	})
+}
+
+// testStore runs the topic lifecycle, re-crawl selection and reply reconciliation on stores from newStore
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	t.Run("transitions", func(t *testing.T) {
+		d := testService(newStore(t), "g")
+		insertTopics(t, d.store(), &Topic{TopicId: "1", GroupId: "g", TopicStatus: string(TopicNew)})
+
+		steps := []struct {
+			to        TopicState
+			update    *Topic
+			cols      []string
+			moved     bool
+			state     TopicState
+			failCount int
+		}{
+			{TopicSynced, &Topic{}, nil, false, TopicNew, 0},
+			{TopicCrawling, &Topic{}, nil, true, TopicCrawling, 0},
+			{TopicFailed, &Topic{}, nil, true, TopicFailed, 1},
+			{TopicCrawling, &Topic{}, nil, true, TopicCrawling, 1},
+			{TopicFailed, &Topic{}, nil, true, TopicFailed, 2},
+			{TopicCrawling, &Topic{}, nil, true, TopicCrawling, 2},
+			{TopicSynced, &Topic{SyncTime: 100, SyncedReplyCount: 7}, []string{"sync_time", "fail_count", "synced_reply_count"}, true, TopicSynced, 0},
+			{TopicSynced, &Topic{}, nil, false, TopicSynced, 0},
+			{TopicDeleted, &Topic{DeletedAt: 200}, []string{"deleted_at"}, true, TopicDeleted, 0},
+			{TopicCrawling, &Topic{}, nil, false, TopicDeleted, 0},
+		}
+		for i, step := range steps {
+			before := time.Now().Unix()
+			moved, err := d.transitionTopic("1", step.to, step.update, step.cols...)
+			if err != nil {
+				t.Fatalf("step %d: %v", i, err)
+			}
+			if moved != step.moved {
+				t.Fatalf("step %d: moving to %s = %v, want %v", i, step.to, moved, step.moved)
+			}
+
+			topic := getTopic(t, d.store(), "1")
+			if TopicState(topic.TopicStatus) != step.state || topic.FailCount != step.failCount {
+				t.Fatalf("step %d: topic is %s with %d failures, want %s with %d", i, topic.TopicStatus, topic.FailCount, step.state, step.failCount)
+			}
+			if moved && topic.StatusTime < before {
+				t.Errorf("step %d: status time %d was not updated", i, topic.StatusTime)
+			}
+		}
+
+		topic := getTopic(t, d.store(), "1")
+		if topic.SyncTime != 100 || topic.SyncedReplyCount != 7 || topic.DeletedAt != 200 {
+			t.Errorf("cols written along were lost: sync %d, synced replies %d, deleted %d", topic.SyncTime, topic.SyncedReplyCount, topic.DeletedAt)
+		}
+
+		moved, err := d.transitionTopic("missing", TopicCrawling, &Topic{})
+		if err != nil || moved {
+			t.Errorf("moving a missing topic = %v, %v, want false", moved, err)
+		}
+	})
+
+	t.Run("ban moves back", func(t *testing.T) {
+		d := testService(newStore(t), "g")
+		insertTopics(t, d.store(), &Topic{TopicId: "1", GroupId: "g", TopicStatus: string(TopicStale), FailCount: 1})
+
+		if _, err := d.transitionTopic("1", TopicCrawling, &Topic{}); err != nil {
+			t.Fatal(err)
+		}
+		update := &Topic{TopicStatus: string(TopicStale), StatusTime: time.Now().Unix()}
+		moved, err := d.store().Topics().Transition("1", []TopicState{TopicCrawling}, update, false)
+		if err != nil || !moved {
+			t.Fatalf("moving back = %v, %v, want true", moved, err)
+		}
+
+		topic := getTopic(t, d.store(), "1")
+		if TopicState(topic.TopicStatus) != TopicStale || topic.FailCount != 1 {
+			t.Errorf("topic is %s with %d failures, want stale with 1", topic.TopicStatus, topic.FailCount)
+		}
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		d := testService(newStore(t), "g")
+		insertTopics(t, d.store(),
+			&Topic{TopicId: "synced", GroupId: "g", TopicStatus: string(TopicSynced), ReplyCount: 5},
+			&Topic{TopicId: "unchanged", GroupId: "g", TopicStatus: string(TopicSynced), ReplyCount: 5},
+			&Topic{TopicId: "crawling", GroupId: "g", TopicStatus: string(TopicCrawling), ReplyCount: 5},
+		)
+
+		tasks, err := d.upsertTopics([]*Topic{
+			{TopicId: "new", GroupId: "g", Title: "new", ReplyCount: 3},
+			{TopicId: "synced", GroupId: "g", Title: "grown", ReplyCount: 9},
+			{TopicId: "unchanged", GroupId: "g", Title: "same", ReplyCount: 5},
+			{TopicId: "crawling", GroupId: "g", Title: "busy", ReplyCount: 8},
+		}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := taskGrowth(tasks), "new:3 synced:4"; got != want {
+			t.Errorf("tasks = %s, want %s", got, want)
+		}
+		wantStates := map[string]TopicState{"new": TopicNew, "synced": TopicStale, "unchanged": TopicSynced, "crawling": TopicCrawling}
+		for id, want := range wantStates {
+			if topic := getTopic(t, d.store(), id); TopicState(topic.TopicStatus) != want {
+				t.Errorf("topic %s is %s, want %s", id, topic.TopicStatus, want)
+			}
+		}
+		if topic := getTopic(t, d.store(), "synced"); topic.Title != "grown" || topic.ReplyCount != 9 {
+			t.Errorf("topic synced was not updated: %q with %d replies", topic.Title, topic.ReplyCount)
+		}
+	})
+
+	t.Run("recrawl", func(t *testing.T) {
+		now := time.Now()
+		ago := func(d time.Duration) int64 { return now.Add(-d).Unix() }
+
+		d := testService(newStore(t), "g")
+		d.client.cfg.WithRecrawl(RecrawlConfig{Batch: 10, MaxAge: time.Hour, MaxFailures: 2, CrawlingTimeout: 10 * time.Minute})
+		insertTopics(t, d.store(),
+			&Topic{TopicId: "stale", GroupId: "g", TopicStatus: string(TopicStale), StatusTime: ago(time.Minute), ReplyCount: 12, SyncedReplyCount: 10},
+			&Topic{TopicId: "failed", GroupId: "g", TopicStatus: string(TopicFailed), StatusTime: ago(2 * time.Hour), FailCount: 1},
+			&Topic{TopicId: "given-up", GroupId: "g", TopicStatus: string(TopicFailed), StatusTime: ago(3 * time.Hour), FailCount: 2},
+			&Topic{TopicId: "old", GroupId: "g", TopicStatus: string(TopicSynced), StatusTime: ago(5 * time.Hour), SyncTime: ago(2 * time.Hour)},
+			&Topic{TopicId: "fresh", GroupId: "g", TopicStatus: string(TopicSynced), StatusTime: ago(6 * time.Hour), SyncTime: ago(time.Minute)},
+			&Topic{TopicId: "interrupted", GroupId: "g", TopicStatus: string(TopicCrawling), StatusTime: ago(time.Hour)},
+			&Topic{TopicId: "running", GroupId: "g", TopicStatus: string(TopicCrawling), StatusTime: ago(time.Minute)},
+			&Topic{TopicId: "other", GroupId: "h", TopicStatus: string(TopicStale), StatusTime: ago(time.Hour)},
+			&Topic{TopicId: "deleted", GroupId: "g", TopicStatus: string(TopicDeleted), StatusTime: ago(time.Hour)},
+		)
+
+		tasks, err := d.recrawlTasks(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := taskGrowth(tasks), "stale:2 failed:0 interrupted:0 old:0"; got != want {
+			t.Errorf("tasks = %s, want %s", got, want)
+		}
+
+		topic := getTopic(t, d.store(), "interrupted")
+		if TopicState(topic.TopicStatus) != TopicFailed || topic.FailCount != 1 {
+			t.Errorf("interrupted topic is %s with %d failures, want failed with 1", topic.TopicStatus, topic.FailCount)
+		}
+		if topic := getTopic(t, d.store(), "running"); TopicState(topic.TopicStatus) != TopicCrawling {
+			t.Errorf("running topic is %s, want crawling", topic.TopicStatus)
+		}
+
+		d.client.cfg.Recrawl.Batch = 2
+		tasks, err = d.recrawlTasks(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := taskGrowth(tasks), "stale:2 failed:0"; got != want {
+			t.Errorf("tasks of a smaller batch = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("reconcile replies", func(t *testing.T) {
+		d := testService(newStore(t), "g")
+		replies := d.store().Replies()
+		if err := replies.Insert([]*Reply{
+			{TopicId: "1", DataCid: "a"}, {TopicId: "1", DataCid: "b"}, {TopicId: "1", DataCid: "c"},
+			{TopicId: "2", DataCid: "x", Images: []string{"https://img1.doubanio.com/x.jpg"}}, {TopicId: "2", DataCid: "y"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := replies.SetDeleted("1", []string{"c"}, time.Now().Add(-time.Hour).Unix()); err != nil {
+			t.Fatal(err)
+		}
+
+		for topicId, seen := range map[string][]string{"1": {"a", "c"}, "2": nil} {
+			stored, err := d.storedReplies(topicId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			present := make(map[string]struct{})
+			for _, cid := range seen {
+				present[cid] = struct{}{}
+			}
+			if err := d.reconcileReplies(d.store(), topicId, stored, present); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stored, err := d.storedReplies("1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored["a"] != 0 || stored["b"] == 0 || stored["c"] != 0 {
+			t.Errorf("deletion times = %v, want only b deleted", stored)
+		}
+
+		from, to := time.Now().Add(-time.Minute), time.Now().Add(time.Minute)
+		deleted, err := d.DeletedReplies("1", from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deleted) != 1 || deleted[0].DataCid != "b" {
+			t.Errorf("deleted replies = %v, want b", deleted)
+		}
+
+		counts, err := d.ReplyDeletions(from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := fmt.Sprint(counts), "[{2 2} {1 1}]"; got != want {
+			t.Errorf("deletion counts = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("store page", func(t *testing.T) {
+		d := testService(newStore(t), "g")
+		insertTopics(t, d.store(), &Topic{TopicId: "1", GroupId: "g", TopicStatus: string(TopicCrawling)})
+
+		detail := testDetail("first")
+		fresh := []*Reply{{TopicId: "1", DataCid: "a", UserId: "u2"}}
+		err := d.storeTopicPage("1", detail, fresh, func(tx Store) error {
+			_, err := d.transitionTopicIn(tx, "1", TopicSynced, &Topic{SyncTime: 1}, "sync_time")
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		topic := getTopic(t, d.store(), "1")
+		if topic.ContentHTML != "<p>first</p>" || topic.UserId != "u1" || TopicState(topic.TopicStatus) != TopicSynced {
+			t.Errorf("topic = %q by %q in %s, want the first body by u1, synced", topic.ContentHTML, topic.UserId, topic.TopicStatus)
+		}
+		assertReplies(t, d, "1", "a")
+
+		// The same body records no revision, a changed one does
+		for _, body := range []string{"first", "second"} {
+			if err := d.storeTopicPage("1", testDetail(body), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		revisions, err := d.TopicRevisions("1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revisions) != 2 || revisions[0].ContentHTML != "<p>first</p>" || revisions[1].ContentHTML != "<p>second</p>" {
+			t.Errorf("revisions = %v, want first and second", revisions)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		d := testService(newStore(t), "g")
+		insertTopics(t, d.store(), &Topic{TopicId: "1", GroupId: "g", TopicStatus: string(TopicCrawling)})
+
+		failed := errors.New("finish failed")
+		fresh := []*Reply{{TopicId: "1", DataCid: "a"}}
+		err := d.storeTopicPage("1", testDetail("lost"), fresh, func(tx Store) error {
+			if _, err := d.transitionTopicIn(tx, "1", TopicSynced, &Topic{}); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Fatalf("storeTopicPage = %v, want %v", err, failed)
+		}
+
+		topic := getTopic(t, d.store(), "1")
+		if topic.ContentHTML != "" || topic.UserId != "" || TopicState(topic.TopicStatus) != TopicCrawling {
+			t.Errorf("topic = %q by %q in %s, want it untouched", topic.ContentHTML, topic.UserId, topic.TopicStatus)
+		}
+		assertReplies(t, d, "1")
+		revisions, err := d.TopicRevisions("1")
+		if err != nil || len(revisions) != 0 {
+			t.Errorf("revisions = %v, %v, want none", revisions, err)
+		}
+	})
+}
+
+func TestCopyCols(t *testing.T) {
+	stored := Topic{Id: 1, TopicId: "1", Title: "old", FailCount: 3, ContentHTML: "<p>old</p>", UserUrl: "old"}
+
+	dst := stored
+	copyCols(&dst, &Topic{Id: 9, Title: "new", FailCount: 0, ContentHTML: "<p>new</p>", UserUrl: "new"}, []string{"fail_count", "content_html", "user_url"})
+	if dst.Id != 1 || dst.Title != "old" || dst.FailCount != 0 || dst.ContentHTML != "<p>new</p>" || dst.UserUrl != "new" {
+		t.Errorf("with cols = %+v, want only the named columns copied, zero values included", dst)
+	}
+
+	dst = stored
+	copyCols(&dst, &Topic{Id: 9, Title: "new"}, nil)
+	if dst.Id != 1 || dst.Title != "new" || dst.FailCount != 3 || dst.ContentHTML != "<p>old</p>" {
+		t.Errorf("without cols = %+v, want only the non-zero fields copied", dst)
+	}
+}
+
+// testService returns a service crawling the groups with ids on store alone, without mysql
+func testService(store Store, ids ...string) *DoubanApiService {
+	cfg := NewConfiguration()
+	for _, id := range ids {
+		cfg.WithID(id)
+	}
+	client := NewAPIClient(cfg)
+	client.Store = store
+	return client.DoubanServiceApi
+}
+
+func testDetail(body string) *topicDetail {
+	html := "<p>" + body + "</p>"
+	return &topicDetail{
+		authorName: "author",
+		authorId:   "u1",
+		authorURL:  "https://www.douban.com/people/u1/",
+		content:    body,
+		rich: richContent{
+			HTML:     html,
+			Markdown: body,
+			Images:   []string{"https://img1.doubanio.com/" + body + ".jpg"},
+			Links:    []string{"https://weibo.com/" + body},
+		},
+		createTime: time.Unix(1700000000, 0),
+	}
+}
+
+func insertTopics(t *testing.T, store Store, topics ...*Topic) {
+	t.Helper()
+	for _, topic := range topics {
+		if err := store.Topics().Insert(topic); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getTopic(t *testing.T, store Store, topicId string) *Topic {
+	t.Helper()
+	topic, err := store.Topics().Get(topicId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic == nil {
+		t.Fatalf("topic %s not found", topicId)
+	}
+	return topic
+}
+
+func assertReplies(t *testing.T, d *DoubanApiService, topicId string, cids ...string) {
+	t.Helper()
+	stored, err := d.storedReplies(topicId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for cid := range stored {
+		got = append(got, cid)
+	}
+	sort.Strings(got)
+	if fmt.Sprint(got) != fmt.Sprint(cids) {
+		t.Errorf("replies of topic %s = %v, want %v", topicId, got, cids)
+	}
+}
+
+// taskGrowth formats tasks as topic:growth in crawl order
+func taskGrowth(tasks []crawlTask) string {
+	var s string
+	for i, task := range tasks {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%s:%d", task.topicId, task.growth)
+	}
+	return s
+}
+
+func TestWithoutMysql(t *testing.T) {
+	d := testService(NewMemoryStore(), "g")
+
+	calls := map[string]func() error{
+		"TopicsByKeyword": func() error { _, err := d.TopicsByKeyword("k"); return err },
+		"TopicPolls":      func() error { _, err := d.TopicPolls("1"); return err },
+		"PollHistory":     func() error { _, err := d.PollHistory("p"); return err },
+		"GroupMemberHistory": func() error {
+			_, err := d.GroupMemberHistory("g", time.Now())
+			return err
+		},
+		"TopicEngagement": func() error { _, err := d.TopicEngagement("1", time.Hour); return err },
+		"HotTopics":       func() error { _, err := d.HotTopics("g", time.Hour, 10); return err },
+		"UpdateGroupInfo": func() error { return d.UpdateGroupInfo("g") },
+		"UpdateUser":      func() error { return d.UpdateUser("u") },
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if err := call(); !errors.Is(err, ErrNoMysql) {
+				t.Errorf("%s() = %v, want %v", name, err, ErrNoMysql)
+			}
+		})
+	}
+
+	// The crawl helpers skip the mysql tables instead
+	if err := d.SyncTables(); err != nil {
+		t.Errorf("SyncTables() = %v", err)
+	}
+	if err := d.markGroupCrawled("g", time.Now()); err != nil {
+		t.Errorf("markGroupCrawled() = %v", err)
+	}
+	if err := d.tagTopics([]*Topic{{TopicId: "1"}}, "g", "k"); err != nil {
+		t.Errorf("tagTopics() = %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+package doubanClient
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// SelectorProfile holds every css selector the douban parsers use, so a markup change
+// can be handled by deploying a new profile instead of a new build
+type SelectorProfile struct {
+	Version   string             `json:"version"`
+	TopicList TopicListSelectors `json:"topic_list"`
+	Search    SearchSelectors    `json:"search"`
+	Topic     TopicSelectors     `json:"topic"`
+	Reply     ReplySelectors     `json:"reply"`
+	Poll      PollSelectors      `json:"poll"`
+	Group     GroupSelectors     `json:"group"`
+	User      UserSelectors      `json:"user"`
+	Page      PageSelectors      `json:"page"`
+}
+
+// TopicListSelectors select the topics on a group discussion page, within Row
+type TopicListSelectors struct {
+	Table         string `json:"table"`
+	Row           string `json:"row"`
+	Title         string `json:"title"`
+	User          string `json:"user"`
+	ReplyCount    string `json:"reply_count"`
+	LastReplyTime string `json:"last_reply_time"`
+	Sticky        string `json:"sticky"`
+	Elite         string `json:"elite"`
+	Locked        string `json:"locked"`
+	HasImages     string `json:"has_images"`
+}
+
+// SearchSelectors select the topics on a group search result page, within Row
+type SearchSelectors struct {
+	Row        string `json:"row"`
+	Title      string `json:"title"`
+	Time       string `json:"time"`
+	ReplyCount string `json:"reply_count"`
+	// Next is the link to the next result page, missing on the last one
+	Next string `json:"next"`
+}
+
+// TopicSelectors select the topic body and author on the first page of a topic
+type TopicSelectors struct {
+	CreateTime string `json:"create_time"`
+	Body       string `json:"body"`
+	Author     string `json:"author"`
+	Deleted    string `json:"deleted"`
+}
+
+// ReplySelectors select the parts of a reply, within Item
+type ReplySelectors struct {
+	Item        string `json:"item"`
+	UserAvatar  string `json:"user_avatar"`
+	UserLink    string `json:"user_link"`
+	Content     string `json:"content"`
+	PubTime     string `json:"pub_time"`
+	LikeCount   string `json:"like_count"`
+	Quote       string `json:"quote"`
+	QuoteAuthor string `json:"quote_author"`
+	QuoteText   string `json:"quote_text"`
+	QuoteShort  string `json:"quote_short"`
+}
+
+// PollSelectors select the polls embedded in a topic; the others are within Item, and Text and Count within Option
+type PollSelectors struct {
+	Item     string `json:"item"`
+	Title    string `json:"title"`
+	Option   string `json:"option"`
+	Text     string `json:"text"`
+	Count    string `json:"count"`
+	Deadline string `json:"deadline"`
+}
+
+// GroupSelectors select the metadata on a group home page; Admins is within Board
+type GroupSelectors struct {
+	Name    string `json:"name"`
+	Intro   string `json:"intro"`
+	Members string `json:"members"`
+	Board   string `json:"board"`
+	Admins  string `json:"admins"`
+	Tags    string `json:"tags"`
+}
+
+// UserSelectors select the profile on a people page; Name, Avatar and Signature are within Profile
+type UserSelectors struct {
+	Profile   string `json:"profile"`
+	Name      string `json:"name"`
+	Signature string `json:"signature"`
+	Avatar    string `json:"avatar"`
+	Location  string `json:"location"`
+	JoinDate  string `json:"join_date"`
+}
+
+// PageSelectors select the markers used to classify any page
+type PageSelectors struct {
+	Captcha  string `json:"captcha"`
+	LoggedIn string `json:"logged_in"`
+}
+
+// DefaultSelectorProfile returns the selectors for the current douban desktop markup
+func DefaultSelectorProfile() SelectorProfile {
+	return SelectorProfile{
+		Version: "desktop-2024.09",
+		TopicList: TopicListSelectors{
+			Table:         "table.olt",
+			Row:           "table.olt tr:not(.th)",
+			Title:         "td.title a",
+			User:          "td:nth-child(2) a",
+			ReplyCount:    "td.r-count",
+			LastReplyTime: "td.time",
+			Sticky:        `td.title img[src*="stick"], td.title img[alt*="置顶"]`,
+			Elite:         `td.title .elite_topic_lable, td.title img[alt*="精华"]`,
+			Locked:        `td.title img[src*="lock"], td.title img[alt*="锁定"], td.title img[alt*="关闭"]`,
+			HasImages:     `td.title .pic-tag, td.title img[src*="pic"]`,
+		},
+		Search: SearchSelectors{
+			Row:        "table.olt tr.pl",
+			Title:      "td.td-subject a",
+			Time:       "td.td-time",
+			ReplyCount: "td.td-reply span",
+			Next:       ".paginator .next a",
+		},
+		Topic: TopicSelectors{
+			CreateTime: ".create-time",
+			Body:       "#link-report > div > div",
+			Author:     ".topic-doc .from a",
+			Deleted:    "#content .topic-deleted, #content .deleted-tips",
+		},
+		Reply: ReplySelectors{
+			Item:        ".comment-item",
+			UserAvatar:  ".user-face img",
+			UserLink:    ".user-face a",
+			Content:     ".reply-content",
+			PubTime:     ".pubtime",
+			LikeCount:   ".reply-opts .comment-vote .count",
+			Quote:       ".reply-quote, .reply-quote-content",
+			QuoteAuthor: ".pubdate a",
+			QuoteText:   ".all",
+			QuoteShort:  ".short",
+		},
+		Poll: PollSelectors{
+			Item:     ".topic-content .poll, .topic-content .vote-poll",
+			Title:    ".poll-title, .vote-title",
+			Option:   ".poll-option, .vote-option",
+			Text:     ".poll-option-text, .vote-option-text",
+			Count:    ".poll-option-count, .vote-option-count",
+			Deadline: ".poll-deadline, .vote-deadline",
+		},
+		Group: GroupSelectors{
+			Name:    "#group-info h1",
+			Intro:   ".group-intro",
+			Members: `a[href*="/members"]`,
+			Board:   ".group-board",
+			Admins:  `a[href*="/people/"]`,
+			Tags:    ".group-tags a",
+		},
+		User: UserSelectors{
+			Profile:   "#db-usr-profile",
+			Name:      ".info h1",
+			Signature: ".signature_display",
+			Avatar:    ".pic img",
+			Location:  ".user-info > a",
+			JoinDate:  ".user-info .pl",
+		},
+		Page: PageSelectors{
+			Captcha:  "#captcha_image, input[name='captcha-solution']",
+			LoggedIn: ".nav-user-account",
+		},
+	}
+}
+
+// LoadSelectorProfile reads a json profile from path. Selectors missing from the file keep their default,
+// so a deployment only needs to list the ones it overrides.
+func LoadSelectorProfile(path string) (SelectorProfile, error) {
+	profile := DefaultSelectorProfile()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return profile, fmt.Errorf("failed to read selector profile: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return profile, fmt.Errorf("failed to parse selector profile: %w", err)
+	}
+	return profile, nil
+}
+
+// PageKind names the kinds of douban pages that are parsed
+type PageKind string
+
+const (
+	PageKindTopicList PageKind = "topic_list"
+	PageKindSearch    PageKind = "search"
+	PageKindTopic     PageKind = "topic"
+	PageKindGroup     PageKind = "group"
+	PageKindUser      PageKind = "user"
+)
+
+// SelectorReport lists the selectors of a profile that matched nothing on a page
+type SelectorReport struct {
+	Kind    PageKind
+	URL     string
+	Version string
+	Missing []string
+}
+
+// OK reports whether every selector matched
+func (r *SelectorReport) OK() bool {
+	return len(r.Missing) == 0
+}
+
+// selectorCheck is a selector expected to match on a page, looked up within scope when one is set
+type selectorCheck struct {
+	name     string
+	scope    string
+	selector string
+}
+
+func (p SelectorProfile) checks(kind PageKind) []selectorCheck {
+	switch kind {
+	case PageKindTopicList:
+		return []selectorCheck{
+			{"topic_list.table", "", p.TopicList.Table},
+			{"topic_list.row", "", p.TopicList.Row},
+			{"topic_list.title", p.TopicList.Row, p.TopicList.Title},
+			{"topic_list.user", p.TopicList.Row, p.TopicList.User},
+			{"topic_list.reply_count", p.TopicList.Row, p.TopicList.ReplyCount},
+			{"topic_list.last_reply_time", p.TopicList.Row, p.TopicList.LastReplyTime},
+		}
+	case PageKindSearch:
+		return []selectorCheck{
+			{"search.row", "", p.Search.Row},
+			{"search.title", p.Search.Row, p.Search.Title},
+			{"search.time", p.Search.Row, p.Search.Time},
+			{"search.reply_count", p.Search.Row, p.Search.ReplyCount},
+		}
+	case PageKindTopic:
+		return []selectorCheck{
+			{"topic.create_time", "", p.Topic.CreateTime},
+			{"topic.body", "", p.Topic.Body},
+		}
+	case PageKindGroup:
+		return []selectorCheck{
+			{"group.name", "", p.Group.Name},
+			{"group.intro", "", p.Group.Intro},
+			{"group.members", "", p.Group.Members},
+			{"group.board", "", p.Group.Board},
+			{"group.admins", p.Group.Board, p.Group.Admins},
+		}
+	case PageKindUser:
+		return []selectorCheck{
+			{"user.profile", "", p.User.Profile},
+			{"user.name", p.User.Profile, p.User.Name},
+			{"user.avatar", p.User.Profile, p.User.Avatar},
+			{"user.join_date", "", p.User.JoinDate},
+		}
+	}
+	return nil
+}
+
+// Validate reports the selectors of the profile that match nothing on doc
+func (p SelectorProfile) Validate(kind PageKind, url string, doc *goquery.Document) *SelectorReport {
+	report := &SelectorReport{Kind: kind, URL: url, Version: p.Version}
+
+	checks := p.checks(kind)
+	// A topic may have no replies, but when it has some every part of them should match
+	if kind == PageKindTopic && doc.Find(p.Reply.Item).Length() > 0 {
+		checks = append(checks,
+			selectorCheck{"reply.user_avatar", p.Reply.Item, p.Reply.UserAvatar},
+			selectorCheck{"reply.user_link", p.Reply.Item, p.Reply.UserLink},
+			selectorCheck{"reply.content", p.Reply.Item, p.Reply.Content},
+			selectorCheck{"reply.pub_time", p.Reply.Item, p.Reply.PubTime},
+		)
+	}
+
+	for _, check := range checks {
+		scope := doc.Selection
+		if check.scope != "" {
+			scope = doc.Find(check.scope)
+		}
+		if check.selector == "" || scope.Find(check.selector).Length() == 0 {
+			report.Missing = append(report.Missing, check.name)
+		}
+	}
+	return report
+}
+
+// ValidatePage fetches a page and reports which selectors of the configured profile matched nothing on it
+func (d *DoubanApiService) ValidatePage(kind PageKind, url string) (*SelectorReport, error) {
+	doc, err := d.fetch(url)
+	if err != nil {
+		return nil, err
+	}
+	return d.client.cfg.Selectors.Validate(kind, url, doc), nil
+}
+
+// validateSelectors logs the selectors that matched nothing when validation is enabled
+func (d *DoubanApiService) validateSelectors(kind PageKind, url string, doc *goquery.Document) {
+	if !d.client.cfg.Client.ValidateSelectors {
+		return
+	}
+
+	report := d.client.cfg.Selectors.Validate(kind, url, doc)
+	if !report.OK() {
+		log.Printf("Selector profile %s matched nothing for %v on %s", report.Version, report.Missing, url)
+	}
+}
@@ -0,0 +1,268 @@
+package doubanClient
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar is an http.CookieJar that can be saved to and loaded from a file,
+// so a logged-in douban session survives between runs
+type CookieJar struct {
+	mu      sync.Mutex
+	path    string
+	cookies map[string]*http.Cookie
+	dirty   bool
+}
+
+// storedCookie is the file layout of a cookie, also accepted from browser json exports
+type storedCookie struct {
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+	Domain         string  `json:"domain"`
+	Path           string  `json:"path"`
+	ExpirationDate float64 `json:"expirationDate"`
+	Secure         bool    `json:"secure"`
+	HttpOnly       bool    `json:"httpOnly"`
+}
+
+// NewCookieJar returns a jar persisted to path, loading the cookies already saved there.
+// An empty path keeps the cookies in memory only.
+func NewCookieJar(path string) (*CookieJar, error) {
+	j := &CookieJar{
+		path:    path,
+		cookies: make(map[string]*http.Cookie),
+	}
+	if path == "" {
+		return j, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookie jar: %w", err)
+	}
+
+	var stored []storedCookie
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse cookie jar: %w", err)
+	}
+	for _, c := range stored {
+		j.add(c.toCookie())
+	}
+	return j, nil
+}
+
+// SetCookies implements http.CookieJar
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, c := range cookies {
+		if c.Domain == "" {
+			c.Domain = u.Hostname()
+		}
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		if c.MaxAge < 0 {
+			delete(j.cookies, cookieKey(c))
+			j.dirty = true
+			continue
+		}
+		if c.MaxAge > 0 {
+			c.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		j.add(c)
+	}
+}
+
+// Cookies implements http.CookieJar
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := u.Hostname()
+	now := time.Now()
+	var cookies []*http.Cookie
+	for key, c := range j.cookies {
+		if !c.Expires.IsZero() && c.Expires.Before(now) {
+			delete(j.cookies, key)
+			j.dirty = true
+			continue
+		}
+		if !domainMatch(host, c.Domain) || !strings.HasPrefix(u.Path+"/", strings.TrimSuffix(c.Path, "/")+"/") {
+			continue
+		}
+		if c.Secure && u.Scheme != "https" {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies
+}
+
+// Import adds the cookies of a browser export, either a Netscape cookies.txt or a json array
+func (j *CookieJar) Import(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read cookie export: %w", err)
+	}
+
+	var cookies []*http.Cookie
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		var stored []storedCookie
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("failed to parse cookie export: %w", err)
+		}
+		for _, c := range stored {
+			cookies = append(cookies, c.toCookie())
+		}
+	} else {
+		cookies, err = parseNetscapeCookies(trimmed)
+		if err != nil {
+			return err
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		j.add(c)
+	}
+	return nil
+}
+
+// Save writes the cookies to the jar file when they changed since the last save
+func (j *CookieJar) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.path == "" || !j.dirty {
+		return nil
+	}
+
+	stored := make([]storedCookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		s := storedCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if !c.Expires.IsZero() {
+			s.ExpirationDate = float64(c.Expires.Unix())
+		}
+		stored = append(stored, s)
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cookie jar: %w", err)
+	}
+
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cookie jar: %w", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("failed to write cookie jar: %w", err)
+	}
+
+	j.dirty = false
+	return nil
+}
+
+// Has reports whether a cookie with the given name is stored
+func (j *CookieJar) Has(name string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, c := range j.cookies {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *CookieJar) add(c *http.Cookie) {
+	key := cookieKey(c)
+	if old, ok := j.cookies[key]; ok && old.Value == c.Value && old.Expires.Equal(c.Expires) {
+		return
+	}
+	j.cookies[key] = c
+	j.dirty = true
+}
+
+func (s storedCookie) toCookie() *http.Cookie {
+	c := &http.Cookie{
+		Name:     s.Name,
+		Value:    s.Value,
+		Domain:   s.Domain,
+		Path:     s.Path,
+		Secure:   s.Secure,
+		HttpOnly: s.HttpOnly,
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if s.ExpirationDate > 0 {
+		c.Expires = time.Unix(int64(s.ExpirationDate), 0)
+	}
+	return c
+}
+
+// parseNetscapeCookies parses the tab separated cookies.txt format:
+// domain, include subdomains, path, secure, expiry, name, value
+func parseNetscapeCookies(data string) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookies.txt line: %q", line)
+		}
+
+		c := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expiry, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expiry > 0 {
+			c.Expires = time.Unix(expiry, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, scanner.Err()
+}
+
+func cookieKey(c *http.Cookie) string {
+	return strings.TrimPrefix(c.Domain, ".") + ";" + c.Path + ";" + c.Name
+}
+
+func domainMatch(host, domain string) bool {
+	domain = strings.TrimPrefix(domain, ".")
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
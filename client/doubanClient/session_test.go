@@ -0,0 +1,200 @@
+package doubanClient
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNetscapeCookies(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []http.Cookie
+		wantErr bool
+	}{
+		{
+			name: "session cookie",
+			data: ".douban.com\tTRUE\t/\tFALSE\t0\tbid\tabc",
+			want: []http.Cookie{{Domain: ".douban.com", Path: "/", Name: "bid", Value: "abc"}},
+		},
+		{
+			name: "secure with expiry",
+			data: "www.douban.com\tFALSE\t/group\tTRUE\t1700000000\tdbcl2\t\"1:x\"",
+			want: []http.Cookie{{Domain: "www.douban.com", Path: "/group", Secure: true, Name: "dbcl2", Value: "\"1:x\"", Expires: time.Unix(1700000000, 0)}},
+		},
+		{
+			name: "http only prefix",
+			data: "#HttpOnly_.douban.com\tTRUE\t/\tFALSE\t0\tck\tv",
+			want: []http.Cookie{{Domain: ".douban.com", Path: "/", Name: "ck", Value: "v", HttpOnly: true}},
+		},
+		{
+			name: "comments and blank lines",
+			data: "# Netscape HTTP Cookie File\n\n.douban.com\tTRUE\t/\tFALSE\t0\ta\t1\n   \n.douban.com\tTRUE\t/\tFALSE\t0\tb\t2\n",
+			want: []http.Cookie{
+				{Domain: ".douban.com", Path: "/", Name: "a", Value: "1"},
+				{Domain: ".douban.com", Path: "/", Name: "b", Value: "2"},
+			},
+		},
+		{
+			name: "empty value",
+			data: ".douban.com\tTRUE\t/\tFALSE\t0\tempty\t",
+			want: []http.Cookie{{Domain: ".douban.com", Path: "/", Name: "empty"}},
+		},
+		{
+			name:    "missing field",
+			data:    ".douban.com\tTRUE\t/\tFALSE\t0\tbid",
+			wantErr: true,
+		},
+		{
+			name:    "space separated",
+			data:    ".douban.com TRUE / FALSE 0 bid abc",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNetscapeCookies(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseNetscapeCookies() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseNetscapeCookies() returned %d cookies, want %d", len(got), len(tt.want))
+			}
+			for i, c := range got {
+				want := tt.want[i]
+				if c.Domain != want.Domain || c.Path != want.Path || c.Secure != want.Secure ||
+					c.HttpOnly != want.HttpOnly || c.Name != want.Name || c.Value != want.Value ||
+					!c.Expires.Equal(want.Expires) {
+					t.Errorf("cookie %d = %+v, want %+v", i, *c, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDomainMatch(t *testing.T) {
+	tests := []struct {
+		host   string
+		domain string
+		want   bool
+	}{
+		{"www.douban.com", "www.douban.com", true},
+		{"www.douban.com", ".douban.com", true},
+		{"www.douban.com", "douban.com", true},
+		{"m.douban.com", ".douban.com", true},
+		{"douban.com", ".douban.com", true},
+		{"a.b.douban.com", "douban.com", true},
+		{"douban.com", "www.douban.com", false},
+		{"notdouban.com", "douban.com", false},
+		{"douban.com.evil.com", "douban.com", false},
+		{"www.douban.com", "m.douban.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host+"/"+tt.domain, func(t *testing.T) {
+			if got := domainMatch(tt.host, tt.domain); got != tt.want {
+				t.Errorf("domainMatch(%q, %q) = %v, want %v", tt.host, tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCookieJarCookies(t *testing.T) {
+	jar, err := NewCookieJar("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origin, _ := url.Parse("https://www.douban.com/")
+	jar.SetCookies(origin, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "site", Value: "2", Domain: ".douban.com"},
+		{Name: "group", Value: "3", Path: "/group"},
+		{Name: "secure", Value: "4", Secure: true},
+		{Name: "expired", Value: "5", Expires: time.Now().Add(-time.Hour)},
+		{Name: "deleted", Value: "6", MaxAge: -1},
+	})
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://www.douban.com/", []string{"host", "secure", "site"}},
+		{"https://www.douban.com/group/topic/1/", []string{"group", "host", "secure", "site"}},
+		{"https://www.douban.com/groups", []string{"host", "secure", "site"}},
+		{"http://www.douban.com/", []string{"host", "site"}},
+		{"https://m.douban.com/", []string{"site"}},
+		{"https://example.com/", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, _ := url.Parse(tt.url)
+			var names []string
+			for _, c := range jar.Cookies(u) {
+				names = append(names, c.Name)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Cookies(%s) = %v, want %v", tt.url, names, tt.want)
+			}
+		})
+	}
+}
+
+func TestCookieJarImportSave(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		export string
+	}{
+		{"netscape", "# Netscape HTTP Cookie File\n.douban.com\tTRUE\t/\tFALSE\t0\tdbcl2\tsession\n"},
+		{"json", `[{"name":"dbcl2","value":"session","domain":".douban.com","path":"/"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export := filepath.Join(dir, tt.name+".export")
+			if err := os.WriteFile(export, []byte(tt.export), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(dir, tt.name+".json")
+			jar, err := NewCookieJar(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := jar.Import(export); err != nil {
+				t.Fatal(err)
+			}
+			if err := jar.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := NewCookieJar(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !loaded.Has("dbcl2") {
+				t.Fatalf("reloaded jar is missing the imported cookie")
+			}
+			u, _ := url.Parse("https://www.douban.com/")
+			if cookies := loaded.Cookies(u); len(cookies) != 1 || cookies[0].Value != "session" {
+				t.Errorf("Cookies() = %v, want dbcl2=session", cookies)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package doubanClient
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shanghai is the zone douban renders all times in. China has no daylight saving,
+// so a fixed zone avoids depending on the tz database being installed.
+var shanghai = time.FixedZone("Asia/Shanghai", 8*60*60)
+
+var relativeTime = regexp.MustCompile(`^(\d+)\s*(秒|分钟|小时|天)前$`)
+
+// absoluteLayouts are the full date forms douban shows, tried in order
+var absoluteLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseDoubanTime parses the time forms shown on douban pages relative to now:
+// full dates, "01-02 15:04" without a year, "今天/昨天 15:04" and relative forms like "刚刚" and "5分钟前"
+func parseDoubanTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	now = now.In(shanghai)
+
+	for _, layout := range absoluteLayouts {
+		if t, err := time.ParseInLocation(layout, s, shanghai); err == nil {
+			return t, nil
+		}
+	}
+
+	// Without a year the date is the latest one not in the future, so replies from December
+	// crawled in January fall in the previous year
+	if t, err := time.ParseInLocation("01-02 15:04", s, shanghai); err == nil {
+		t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, shanghai)
+		if t.After(now.Add(time.Hour * 24)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, nil
+	}
+
+	if s == "刚刚" {
+		return now, nil
+	}
+
+	if m := relativeTime.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "秒":
+			return now.Add(-time.Duration(n) * time.Second), nil
+		case "分钟":
+			return now.Add(-time.Duration(n) * time.Minute), nil
+		case "小时":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "天":
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+
+	for prefix, days := range map[string]int{"今天": 0, "昨天": -1, "前天": -2} {
+		if !strings.HasPrefix(s, prefix) {
+			continue
+		}
+		clock, err := time.ParseInLocation("15:04", strings.TrimSpace(strings.TrimPrefix(s, prefix)), shanghai)
+		if err != nil {
+			break
+		}
+		day := now.AddDate(0, 0, days)
+		return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, shanghai), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time format: %s", s)
+}
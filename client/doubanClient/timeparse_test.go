@@ -0,0 +1,93 @@
+package doubanClient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDoubanTime(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 30, 0, 0, shanghai)
+	at := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, shanghai)
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"full date with seconds", now, "2023-05-06 07:08:09", at(2023, 5, 6, 7, 8, 9), false},
+		{"full date", now, "2023-05-06 07:08", at(2023, 5, 6, 7, 8, 0), false},
+		{"date only", now, "2023-05-06", at(2023, 5, 6, 0, 0, 0), false},
+		{"surrounding space", now, "  2023-05-06 07:08 \n", at(2023, 5, 6, 7, 8, 0), false},
+		{"without year", now, "01-09 08:00", at(2024, 1, 9, 8, 0, 0), false},
+		{"without year from december", now, "12-31 23:59", at(2023, 12, 31, 23, 59, 0), false},
+		{"without year later today", now, "01-10 18:00", at(2024, 1, 10, 18, 0, 0), false},
+		{"without year tomorrow", now, "01-11 08:00", at(2024, 1, 11, 8, 0, 0), false},
+		{"without year next week", now, "01-17 08:00", at(2023, 1, 17, 8, 0, 0), false},
+		{"just now", now, "刚刚", now, false},
+		{"seconds ago", now, "30秒前", now.Add(-30 * time.Second), false},
+		{"minutes ago", now, "5分钟前", now.Add(-5 * time.Minute), false},
+		{"minutes ago with space", now, "5 分钟前", now.Add(-5 * time.Minute), false},
+		{"hours ago", now, "3小时前", now.Add(-3 * time.Hour), false},
+		{"days ago", now, "2天前", at(2024, 1, 8, 12, 30, 0), false},
+		{"today", now, "今天 09:15", at(2024, 1, 10, 9, 15, 0), false},
+		{"yesterday", now, "昨天 23:00", at(2024, 1, 9, 23, 0, 0), false},
+		{"day before yesterday across a month", at(2024, 3, 1, 10, 0, 0), "前天 08:00", at(2024, 2, 28, 8, 0, 0), false},
+		{"now in another zone", now.UTC(), "今天 09:15", at(2024, 1, 10, 9, 15, 0), false},
+		{"empty", now, "", time.Time{}, true},
+		{"unknown relative unit", now, "3周前", time.Time{}, true},
+		{"today without clock", now, "今天", time.Time{}, true},
+		{"garbage", now, "last tuesday", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDoubanTime(tt.in, tt.now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseDoubanTime(%q) = %s, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseDoubanTime(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRecentTime(t *testing.T) {
+	format := func(d time.Duration) string {
+		return time.Now().Add(-d).In(shanghai).Format("2006-01-02 15:04:05")
+	}
+
+	tests := []struct {
+		name     string
+		in       string
+		interval time.Duration
+		want     bool
+	}{
+		{"inside the interval", format(time.Hour), 24 * time.Hour, true},
+		{"outside the interval", format(25 * time.Hour), 24 * time.Hour, false},
+		{"relative inside", "5分钟前", time.Hour, true},
+		{"relative outside", "2天前", 24 * time.Hour, false},
+		{"just now", "刚刚", time.Hour, true},
+		{"unparseable is kept", "unknown", time.Hour, true},
+		{"no interval keeps everything", format(24 * 365 * time.Hour), 0, true},
+	}
+
+	var d *DoubanApiService
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.isRecentTime(tt.in, tt.interval); got != tt.want {
+				t.Errorf("isRecentTime(%q, %s) = %v, want %v", tt.in, tt.interval, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package doubanClient
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// crawlTask is a topic whose replies need crawling
+type crawlTask struct {
+	topicId  string
+	interval time.Duration
+	// growth is how many replies the topic gained since it was last listed or synced
+	growth int
+}
+
+// crawlTopics crawls the replies of tasks on ClientConfig.Workers workers, the topics that grew most first.
+// Workers share the per-host request budgets and ban guards, so the budget holds whatever the number of workers.
+// After a ban of the site topics are crawled from no more tasks are started and the ban is returned.
+func (d *DoubanApiService) crawlTopics(tasks []crawlTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].growth > tasks[j].growth
+	})
+
+	workers := d.client.cfg.Client.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	var (
+		mu      sync.Mutex
+		blocked error
+		wg      sync.WaitGroup
+	)
+	queue := make(chan crawlTask)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				err := d.crawlTopic(task.topicId, task.interval)
+				if err == nil {
+					continue
+				}
+				if d.isBlocking(err) {
+					mu.Lock()
+					if blocked == nil {
+						blocked = err
+					}
+					mu.Unlock()
+					continue
+				}
+				log.Printf("Failed to update replies for topic %s: %v", task.topicId, err)
+			}
+		}()
+	}
+
+	for _, task := range tasks {
+		mu.Lock()
+		stop := blocked != nil
+		mu.Unlock()
+		if stop {
+			break
+		}
+		queue <- task
+	}
+	close(queue)
+	wg.Wait()
+
+	return blocked
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/kosmosCosmos/arc-golang-toolkit/tools"
 	"github.com/tidwall/gjson"
 	"log"
 	"sync"
@@ -13,7 +12,7 @@ import (
 type PocketApiService service
 
 func (p *PocketApiService) UpdateChannelInfo() error {
-	_, body, err := tools.NewRequest("POST", p.client.cfg.API.FriendshipsURL, p.client.cfg.Service.Header, nil)
+	body, err := p.request(p.client.cfg.API.FriendshipsURL, nil)
 	if err != nil {
 		return fmt.Errorf("http获取失败: %w", err)
 	}
@@ -60,7 +59,7 @@ func (p *PocketApiService) getServerIDForStarID(starID int) (int, error) {
 		"tabId":      0,
 		"starId":     starID,
 	}
-	_, body, err := tools.NewRequest("POST", p.client.cfg.API.IMServerJumpURL, p.client.cfg.Service.Header, payload)
+	body, err := p.request(p.client.cfg.API.IMServerJumpURL, payload)
 	if err != nil {
 		return 0, err
 	}
@@ -72,7 +71,7 @@ func (p *PocketApiService) getLastMsgList(serverID int) ([]gjson.Result, error)
 	channelPayload := map[string]interface{}{
 		"serverId": serverID,
 	}
-	_, channelBody, err := tools.NewRequest("POST", p.client.cfg.API.TeamLastMessageURL, p.client.cfg.Service.Header, channelPayload)
+	channelBody, err := p.request(p.client.cfg.API.TeamLastMessageURL, channelPayload)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +83,7 @@ func (p *PocketApiService) getChannelInfo(channelID int) (gjson.Result, error) {
 	infoPayload := map[string]interface{}{
 		"channelId": channelID,
 	}
-	_, infoBody, err := tools.NewRequest("POST", p.client.cfg.API.TeamRoomInfoURL, p.client.cfg.Service.Header, infoPayload)
+	infoBody, err := p.request(p.client.cfg.API.TeamRoomInfoURL, infoPayload)
 	if err != nil {
 		return gjson.Result{}, err
 	}
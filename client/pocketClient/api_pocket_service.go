@@ -1 +1,112 @@
 package pocketClient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kosmosCosmos/arc-crawling-service/auth"
+	"github.com/kosmosCosmos/arc-golang-toolkit/tools"
+	"github.com/redis/go-redis/v9"
+	"github.com/tidwall/gjson"
+)
+
+// Status codes pocket returns when the token is missing or has expired
+const (
+	statusUnauthorized = 401
+	statusTokenExpired = 401004
+)
+
+// ErrTokenExpired is returned when the token has expired and no re-login is configured
+var ErrTokenExpired = errors.New("pocket token expired")
+
+// request sends an authenticated request, logging in again and retrying once when the token has expired
+func (p *PocketApiService) request(url string, payload interface{}) (string, error) {
+	token, err := p.loadToken()
+	if err != nil {
+		return "", err
+	}
+
+	body, err := p.send(url, token, payload)
+	if err != nil || !isTokenExpired(body) {
+		return body, err
+	}
+
+	if err := p.relogin(token); err != nil {
+		return "", err
+	}
+
+	token, err = p.loadToken()
+	if err != nil {
+		return "", err
+	}
+
+	body, err = p.send(url, token, payload)
+	if err != nil {
+		return "", err
+	}
+	if isTokenExpired(body) {
+		return "", ErrTokenExpired
+	}
+	return body, nil
+}
+
+func (p *PocketApiService) send(url, token string, payload interface{}) (string, error) {
+	header := make(map[string]string, len(p.client.cfg.Service.Header)+1)
+	for k, v := range p.client.cfg.Service.Header {
+		header[k] = v
+	}
+	if token != "" {
+		header["token"] = token
+	}
+
+	_, body, err := tools.NewRequest("POST", url, header, payload)
+	return body, err
+}
+
+func (p *PocketApiService) loadToken() (string, error) {
+	stored, err := p.client.RedisClient.Get(context.Background(), auth.TokenKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("redis获取token失败: %w", err)
+	}
+
+	token, err := p.client.TokenKeyring.Open(stored)
+	if err != nil {
+		return "", fmt.Errorf("解密token失败: %w", err)
+	}
+	return token, nil
+}
+
+// relogin refreshes the token unless another request already replaced staleToken
+func (p *PocketApiService) relogin(staleToken string) error {
+	p.client.reloginMu.Lock()
+	defer p.client.reloginMu.Unlock()
+
+	current, err := p.loadToken()
+	if err != nil {
+		return err
+	}
+	if current != staleToken {
+		return nil
+	}
+
+	if p.client.Authenticator == nil || p.client.CodeProvider == nil {
+		return ErrTokenExpired
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.client.cfg.Service.LoginTimeout)
+	defer cancel()
+
+	if _, err := p.client.Authenticator.Relogin(ctx, p.client.CodeProvider); err != nil {
+		return fmt.Errorf("自动登录失败: %w", err)
+	}
+	return nil
+}
+
+func isTokenExpired(body string) bool {
+	status := gjson.Get(body, "status").Int()
+	return status == statusUnauthorized || status == statusTokenExpired
+}
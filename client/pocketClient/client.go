@@ -1,6 +1,9 @@
 package pocketClient
 
 import (
+	"sync"
+
+	"github.com/kosmosCosmos/arc-crawling-service/auth"
 	"github.com/redis/go-redis/v9"
 	"xorm.io/xorm"
 )
@@ -12,6 +15,14 @@ type APIClient struct {
 	MysqlClient      *xorm.Engine
 	RedisClient      *redis.Client
 	PocketServiceApi *PocketApiService
+
+	// Authenticator and CodeProvider enable automatic re-login when the token expires
+	Authenticator *auth.Authenticator
+	CodeProvider  auth.CodeProvider
+	reloginMu     sync.Mutex
+
+	// TokenKeyring decrypts the stored token when it was saved encrypted
+	TokenKeyring *auth.Keyring
 }
 
 type service struct {
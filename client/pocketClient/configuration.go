@@ -2,6 +2,8 @@ package pocketClient
 
 import (
 	"time"
+
+	"github.com/kosmosCosmos/arc-crawling-service/auth"
 )
 
 // APIConfig stores API-specific configuration
@@ -19,8 +21,9 @@ type APIConfig struct {
 
 // ServiceConfig stores client-specific configuration
 type ServiceConfig struct {
-	Header   map[string]string
-	Interval time.Duration
+	Header       map[string]string
+	Interval     time.Duration
+	LoginTimeout time.Duration
 }
 
 // Configuration stores the configuration of the API client
@@ -31,7 +34,7 @@ type Configuration struct {
 
 // DefaultHeader returns the default header configuration
 func DefaultHeader() map[string]string {
-	return map[string]string{"User-Agent": "PocketFans201807/7.0.2_22090903 (MuMu:Android 6.0.1;Netease V417IR release-keys)", "Host": "pocketapi.48.cn", "Connection": "Keep-Alive", "Accept-Encoding": "gzip", "appInfo": `{"IMEI":"997bfc558cc69ae6","appBuild":"22090903","appVersion":"7.0.2","deviceId":"997bfc558cc69ae6","deviceName":"MuMu","osType":"android","osVersion":"6.0.1","phoneName":"MuMu","phoneSystemVersion":"6.0.1","vendor":"Netease"}`, "Content-Type": "application/json; charset=UTF-8"}
+	return auth.DefaultHeader()
 }
 
 // NewConfiguration returns a new Configuration object
@@ -49,8 +52,9 @@ func NewConfiguration() *Configuration {
 			TeamRoomInfoURL:    "https://pocketapi.48.cn/im/api/v1/im/team/room/info",
 		},
 		Service: ServiceConfig{
-			Header:   DefaultHeader(),
-			Interval: time.Hour * 24,
+			Header:       DefaultHeader(),
+			Interval:     time.Hour * 24,
+			LoginTimeout: time.Minute * 10,
 		},
 	}
 
@@ -63,6 +67,20 @@ func (c *Configuration) WithInterval(interval time.Duration) *Configuration {
 	return c
 }
 
+// WithLoginTimeout sets how long an automatic re-login waits for the verification code
+func (c *Configuration) WithLoginTimeout(timeout time.Duration) *Configuration {
+	c.Service.LoginTimeout = timeout
+	return c
+}
+
+// WithDeviceProfile replaces the device headers with the ones generated from profile
+func (c *Configuration) WithDeviceProfile(profile auth.DeviceProfile) *Configuration {
+	for key, value := range profile.Header() {
+		c.Service.Header[key] = value
+	}
+	return c
+}
+
 // WithCustomHeader sets a custom header for the configuration
 func (c *Configuration) WithCustomHeader(key, value string) *Configuration {
 	c.Service.Header[key] = value
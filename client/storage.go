@@ -0,0 +1,20 @@
+package client
+
+import (
+	"fmt"
+
+	"xorm.io/xorm"
+)
+
+// OpenSQLite opens a SQLite database for single-box deployments, to be passed where a mysql engine is.
+// The driver is only linked into builds tagged sqlite, other builds fail to open the database.
+func OpenSQLite(path string) (*xorm.Engine, error) {
+	engine, err := xorm.NewEngine("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// SQLite allows a single writer, so parallel crawl workers share one connection instead of failing on locks
+	engine.SetMaxOpenConns(1)
+	return engine, nil
+}
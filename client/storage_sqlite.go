@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package client
+
+// The SQLite driver needs cgo, so it is only linked into builds tagged sqlite
+import _ "github.com/mattn/go-sqlite3"
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kosmosCosmos/arc-crawling-service/auth"
+	"github.com/redis/go-redis/v9"
+)
+
+const usage = `usage: pocket-auth <command> [flags]
+
+commands:
+  send     send an SMS code to the phone number, for a later login -no-sms
+  login    send an SMS code to the phone number and log in with it
+  check    report whether the stored token is still accepted
+  rotate   re-encrypt the stored token with the primary key`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "send":
+		if err := runSend(os.Args[2:]); err != nil {
+			log.Fatalf("send failed: %v", err)
+		}
+	case "login":
+		if err := runLogin(os.Args[2:]); err != nil {
+			log.Fatalf("login failed: %v", err)
+		}
+	case "check":
+		if err := runCheck(os.Args[2:]); err != nil {
+			log.Fatalf("check failed: %v", err)
+		}
+	case "rotate":
+		if err := runRotate(os.Args[2:]); err != nil {
+			log.Fatalf("rotate failed: %v", err)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func runSend(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	mobile := fs.String("mobile", "", "phone number of the pocket account")
+	area := fs.String("area", "86", "phone area code")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *mobile == "" {
+		return fmt.Errorf("-mobile is required")
+	}
+
+	if err := auth.NewAuthenticator(*mobile, *area, nil).SendSMS(); err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	log.Printf("Verification code sent to +%s %s", *area, *mobile)
+	return nil
+}
+
+func runLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	mobile := fs.String("mobile", "", "phone number of the pocket account")
+	area := fs.String("area", "86", "phone area code")
+	redisClient := redisFlags(fs)
+	keyring := keyringFlags(fs)
+	codeFile := fs.String("code-file", "", "wait for the verification code to be written to this file instead of reading stdin")
+	codeEnv := fs.String("code-env", "", "read the verification code from this environment variable instead of stdin, "+
+		"it was set before the command ran so this implies -no-sms: send the code with the send command first")
+	wait := fs.Duration("wait", 5*time.Minute, "how long to wait for the verification code")
+	noSMS := fs.Bool("no-sms", false, "do not send a new SMS, use a code that was already received")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *mobile == "" {
+		return fmt.Errorf("-mobile is required")
+	}
+	if *codeEnv != "" {
+		// The variable cannot hold the code of an SMS sent after the command started
+		*noSMS = true
+	}
+
+	keys, err := keyring()
+	if err != nil {
+		return err
+	}
+
+	rdb := redisClient()
+	defer rdb.Close()
+
+	var provider auth.CodeProvider = auth.NewStdinCodeProvider()
+	if *codeFile != "" {
+		provider = auth.NewFileCodeProvider(*codeFile)
+	}
+
+	authenticator := auth.NewAuthenticator(*mobile, *area, rdb).WithKeyring(keys)
+	if !*noSMS {
+		// A code file left from an earlier SMS must not be taken for the new code
+		if r, ok := provider.(auth.CodeResetter); ok {
+			r.Reset()
+		}
+		if err := authenticator.SendSMS(); err != nil {
+			return fmt.Errorf("failed to send SMS: %w", err)
+		}
+		log.Printf("Verification code sent to +%s %s", *area, *mobile)
+	}
+
+	var code string
+	if *codeEnv != "" {
+		code = strings.TrimSpace(os.Getenv(*codeEnv))
+		if code == "" {
+			return fmt.Errorf("environment variable %s is empty", *codeEnv)
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), *wait)
+		defer cancel()
+
+		code, err = provider.Code(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get verification code: %w", err)
+		}
+	}
+
+	token, err := authenticator.Login(code)
+	if err != nil {
+		return err
+	}
+
+	printToken(token)
+	return nil
+}
+
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	redisClient := redisFlags(fs)
+	keyring := keyringFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	keys, err := keyring()
+	if err != nil {
+		return err
+	}
+
+	rdb := redisClient()
+	defer rdb.Close()
+
+	status, err := auth.NewAuthenticator("", "", rdb).WithKeyring(keys).Check()
+	if err != nil {
+		return err
+	}
+
+	printToken(status.Token)
+	if !status.Valid {
+		return fmt.Errorf("token rejected: %s", status.Message)
+	}
+	fmt.Println("valid: true")
+	return nil
+}
+
+func runRotate(args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	redisClient := redisFlags(fs)
+	keyring := keyringFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	keys, err := keyring()
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return fmt.Errorf("-key-env or -key-file is required")
+	}
+
+	rdb := redisClient()
+	defer rdb.Close()
+
+	rotated, err := auth.NewAuthenticator("", "", rdb).WithKeyring(keys).RotateKey()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("rotated: %t\n", rotated)
+	return nil
+}
+
+// redisFlags registers the redis connection flags and returns a constructor for the client
+func redisFlags(fs *flag.FlagSet) func() *redis.Client {
+	addr := fs.String("redis-addr", "localhost:6379", "redis address the token is stored in")
+	password := fs.String("redis-password", "", "redis password")
+	db := fs.Int("redis-db", 0, "redis database")
+	return func() *redis.Client {
+		return redis.NewClient(&redis.Options{
+			Addr:     *addr,
+			Password: *password,
+			DB:       *db,
+		})
+	}
+}
+
+// keyringFlags registers the token encryption flags and returns a loader for the keyring,
+// which is nil when encryption is not configured
+func keyringFlags(fs *flag.FlagSet) func() (*auth.Keyring, error) {
+	env := fs.String("key-env", "", "environment variable holding the token encryption keys")
+	file := fs.String("key-file", "", "file holding the token encryption keys")
+	return func() (*auth.Keyring, error) {
+		switch {
+		case *file != "":
+			return auth.LoadKeyringFromFile(*file)
+		case *env != "":
+			return auth.LoadKeyringFromEnv(*env)
+		default:
+			return nil, nil
+		}
+	}
+}
+
+func printToken(token *auth.Token) {
+	expiry := "unknown"
+	if !token.ExpiresAt.IsZero() {
+		expiry = token.ExpiresAt.Format(time.RFC3339)
+	}
+	fmt.Printf("user id: %d\nnickname: %s\nexpires: %s\n", token.UserID, token.Nickname, expiry)
+}